@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateAuditMethods 生成collectChanges，按脏标记收集字段修改，仅在audit模式生成
+func generateAuditMethods(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	if !audit {
+		return
+	}
+	fieldChange := g.QualifiedGoIdent(ormPackage.Ident("FieldChange"))
+
+	g.P("// collectChanges 按脏标记收集字段修改及原值，调用方需持有聚合根的锁")
+	g.P("// 未整体替换的嵌套消息展开为子字段路径；主键和版本字段不记录")
+	g.P("func (x *", structName, ") collectChanges(prefix string, changes []", fieldChange, ") []", fieldChange, " {")
+	g.P("\tif x == nil || x.Dirty == nil {")
+	g.P("\t\treturn changes")
+	g.P("\t}")
+	for _, field := range message.Fields {
+		opts := getFieldOptions(field)
+		if opts.GetPrimaryKey() || opts.GetVersion() {
+			continue
+		}
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+		bsonName := getBsonName(field)
+
+		g.P("\tif x.isFieldDirty(", constName, ") {")
+		indent := "\t\t"
+		if isMessageField(field) {
+			g.P("\t\tif !x.isFieldReplaced(", constName, ") {")
+			g.P("\t\t\tchanges = x.", fieldName, ".collectChanges(prefix+\"", bsonName, ".\", changes)")
+			g.P("\t\t} else {")
+			indent = "\t\t\t"
+		}
+		g.P(indent, "var oldValue interface{}")
+		g.P(indent, "if x.isOriginalCaptured(", constName, ") {")
+		g.P(indent, "\toldValue = ", auditValueExpr(field, "x.Dirty.original."+fieldName))
+		g.P(indent, "}")
+		g.P(indent, "changes = append(changes, ", ormPackage.Ident("NewFieldChange"), "(prefix+\"", bsonName, "\", oldValue, ", auditValueExpr(field, "x."+fieldName), "))")
+		if isMessageField(field) {
+			g.P("\t\t}")
+		}
+		g.P("\t}")
+	}
+	g.P("\treturn changes")
+	g.P("}")
+	g.P()
+
+	g.P("// auditValue 返回写入审计记录的完整文档，nil对象记为null")
+	g.P("func (x *", structName, ") auditValue() interface{} {")
+	g.P("\tif x == nil {")
+	g.P("\t\treturn nil")
+	g.P("\t}")
+	g.P("\treturn x.toBSON()")
+	g.P("}")
+	g.P()
+}
+
+// auditValueExpr 返回写入审计记录的字段值表达式，嵌套消息展开为文档
+func auditValueExpr(field *protogen.Field, expr string) string {
+	if isMessageField(field) {
+		return expr + ".auditValue()"
+	}
+	return expr
+}
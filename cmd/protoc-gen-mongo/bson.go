@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+func generateBSONMethods(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	generateMarshalBSON(g, message, structName)
+	generateUnmarshalBSON(g, message, structName)
+	generateBuildUpdate(g, message, structName)
+}
+
+// generateMarshalBSON 生成MarshalBSON方法，输出完整文档
+func generateMarshalBSON(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	g.P("// MarshalBSON 实现bson.Marshaler接口，输出完整文档")
+	g.P("func (x *", structName, ") MarshalBSON() ([]byte, error) {")
+	generateLock(g, false)
+	if getMessageOptions(message).GetSchemaVersion() > 0 {
+		g.P("\tdoc := x.toBSON()")
+		g.P("\tdoc = append(doc, ", bsonPackage.Ident("E"), "{Key: ", ormPackage.Ident("SchemaVersionField"), ", Value: ", structName, "SchemaVersion})")
+		g.P("\treturn ", bsonPackage.Ident("Marshal"), "(doc)")
+	} else {
+		g.P("\treturn ", bsonPackage.Ident("Marshal"), "(x.toBSON())")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// toBSON 构建完整文档，嵌套消息直接展开，不经过子对象的MarshalBSON")
+	g.P("func (x *", structName, ") toBSON() ", bsonPackage.Ident("D"), " {")
+	g.P("\tdoc := ", bsonPackage.Ident("D"), "{}")
+	for _, field := range message.Fields {
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		bsonName := getBsonName(field)
+		if isArrayOrMap(field) {
+			// 空数组和空字典写成[]和{}而不是null，保证后续按元素更新可用
+			g.P("\tif x.", fieldName, " == nil {")
+			g.P("\t\tdoc = append(doc, ", bsonPackage.Ident("E"), "{Key: \"", bsonName, "\", Value: ", getGoType(field), "{}})")
+			g.P("\t} else {")
+			g.P("\t\tdoc = append(doc, ", bsonPackage.Ident("E"), "{Key: \"", bsonName, "\", Value: x.", fieldName, "})")
+			g.P("\t}")
+			continue
+		}
+		if isMessageField(field) {
+			g.P("\tif x.", fieldName, " == nil {")
+			g.P("\t\tdoc = append(doc, ", bsonPackage.Ident("E"), "{Key: \"", bsonName, "\", Value: nil})")
+			g.P("\t} else {")
+			g.P("\t\tdoc = append(doc, ", bsonPackage.Ident("E"), "{Key: \"", bsonName, "\", Value: x.", fieldName, ".toBSON()})")
+			g.P("\t}")
+			continue
+		}
+		g.P("\tdoc = append(doc, ", bsonPackage.Ident("E"), "{Key: \"", bsonName, "\", Value: x.", fieldName, "})")
+	}
+	g.P("\treturn doc")
+	g.P("}")
+	g.P()
+}
+
+// generateUnmarshalBSON 生成UnmarshalBSON方法，加载后的对象没有脏标记
+func generateUnmarshalBSON(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	if getMessageOptions(message).GetSchemaVersion() > 0 {
+		generateVersionedUnmarshal(g, message, structName)
+		return
+	}
+	g.P("// UnmarshalBSON 实现bson.Unmarshaler接口，加载后的对象没有脏标记")
+	generateLockedMethod(g, structName, "UnmarshalBSON", "data []byte", "data", "error", true, func() {
+		generateUnmarshalBody(g, message, structName)
+	})
+}
+
+// generateUnmarshalBody 生成UnmarshalBSON的方法体，嵌套消息调用子对象的无锁实现
+// 脏状态在解码前清除，从旧键名读取的字段在解码后重新标脏
+func generateUnmarshalBody(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	g.P("\telements, err := ", bsonPackage.Ident("Raw"), "(data).Elements()")
+	g.P("\tif err != nil {")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\tx.", internalName("EnsureDirty"), "()")
+	g.P("\tx.", internalName("ResetDirty"), "()")
+	if hasPreviousNames(message) {
+		g.P("\tvar migrated []int")
+	}
+	g.P("\tfor _, element := range elements {")
+	g.P("\t\tvalue := element.Value()")
+	g.P("\t\tswitch element.Key() {")
+	for _, field := range message.Fields {
+		g.P("\t\tcase \"", getBsonName(field), "\":")
+		generateFieldDecode(g, field)
+		generateLegacyCase(g, structName, field)
+	}
+	g.P("\t\t}")
+	g.P("\t}")
+
+	for _, field := range message.Fields {
+		if isMessageField(field) {
+			fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+			constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+			g.P("\tif x.", fieldName, " != nil {")
+			g.P("\t\tx.", fieldName, ".", internalName("SetParentNotifier"), "(x, ", constName, ")")
+			g.P("\t}")
+		}
+	}
+	generatePartialReset(g, message)
+	generateMigratedDirty(g, message, structName)
+	generateUpgradedDirty(g, message)
+	g.P("\treturn nil")
+}
+
+// generateFieldDecode 生成把value解码到字段的代码，嵌套消息不是文档时置为nil
+func generateFieldDecode(g *protogen.GeneratedFile, field *protogen.Field) {
+	fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+	if isMessageField(field) {
+		g.P("\t\t\tdoc, ok := value.DocumentOK()")
+		g.P("\t\t\tif !ok {")
+		g.P("\t\t\t\tx.", fieldName, " = nil")
+		g.P("\t\t\t\tcontinue")
+		g.P("\t\t\t}")
+		g.P("\t\t\tx.", fieldName, " = New", field.Message.GoIdent.GoName, "()")
+		g.P("\t\t\tif err := x.", fieldName, ".", internalName("UnmarshalBSON"), "(doc); err != nil {")
+		g.P("\t\t\t\treturn err")
+		g.P("\t\t\t}")
+		return
+	}
+	g.P("\t\t\tif err := value.Unmarshal(&x.", fieldName, "); err != nil {")
+	g.P("\t\t\t\treturn err")
+	g.P("\t\t\t}")
+}
+
+// generateBuildUpdate 生成根据脏标记构建增量更新文档的方法
+func generateBuildUpdate(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	g.P("// BuildUpdate 根据脏标记构建增量更新文档，没有变更时返回nil")
+	g.P("// 主键和版本字段不会出现在更新中，由Repository负责处理")
+	generateLockedMethod(g, structName, "BuildUpdate", "", "", g.QualifiedGoIdent(bsonPackage.Ident("M")), false, func() {
+		g.P("\tset, unset, inc := ", bsonPackage.Ident("M"), "{}, ", bsonPackage.Ident("M"), "{}, ", bsonPackage.Ident("M"), "{}")
+		g.P("\tx.collectDirtyUpdate(\"\", set, unset, inc)")
+		g.P("\tupdate := ", bsonPackage.Ident("M"), "{}")
+		g.P("\tif len(set) > 0 {")
+		g.P("\t\tupdate[\"$set\"] = set")
+		g.P("\t}")
+		g.P("\tif len(unset) > 0 {")
+		g.P("\t\tupdate[\"$unset\"] = unset")
+		g.P("\t}")
+		g.P("\tif len(inc) > 0 {")
+		g.P("\t\tupdate[\"$inc\"] = inc")
+		g.P("\t}")
+		g.P("\tif len(update) == 0 {")
+		g.P("\t\treturn nil")
+		g.P("\t}")
+		g.P("\treturn update")
+	})
+
+	g.P("// collectDirtyUpdate 按点分路径收集脏字段的$set、$unset和$inc项")
+	g.P("// 字典按键展开，嵌套消息按子路径展开，被整体替换时写入整个值；decimal字段只经由Inc修改时写入增量")
+	g.P("func (x *", structName, ") collectDirtyUpdate(prefix string, set, unset, inc ", bsonPackage.Ident("M"), ") {")
+	g.P("\tif x == nil || x.Dirty == nil {")
+	g.P("\t\treturn")
+	g.P("\t}")
+	for _, field := range message.Fields {
+		opts := getFieldOptions(field)
+		if opts.GetPrimaryKey() || opts.GetVersion() {
+			continue
+		}
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		bsonName := getBsonName(field)
+		constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+
+		g.P("\tif x.isFieldDirty(", constName, ") {")
+		for _, name := range opts.GetPreviousNames() {
+			// 迁移后删除旧键名，文档中不存在时$unset不起作用
+			g.P("\t\tunset[prefix+\"", name, "\"] = \"\"")
+		}
+		switch {
+		case field.Desc.IsMap():
+			publicFieldName := strings.Title(fieldName)
+			keyType, _ := getMapTypes(field)
+			keyPath := "k"
+			if keyType != "string" {
+				keyPath = g.QualifiedGoIdent(fmtPackage.Ident("Sprint")) + "(k)"
+			}
+			g.P("\t\tif x.isFieldReplaced(", constName, ") || x.", fieldName, " == nil {")
+			g.P("\t\t\tif x.", fieldName, " == nil {")
+			g.P("\t\t\t\tset[prefix+\"", bsonName, "\"] = ", getGoType(field), "{}")
+			g.P("\t\t\t} else {")
+			if needsCopy(field) {
+				generateCopyValue(g, field, "\t\t\t\t", "v", "x."+fieldName)
+				g.P("\t\t\t\tset[prefix+\"", bsonName, "\"] = v")
+			} else {
+				g.P("\t\t\t\tset[prefix+\"", bsonName, "\"] = x.", fieldName)
+			}
+			g.P("\t\t\t}")
+			g.P("\t\t} else {")
+			g.P("\t\t\tfor key := range x.Dirty.", publicFieldName, "Elements {")
+			g.P("\t\t\t\tk := key.(", keyType, ")")
+			g.P("\t\t\t\tpath := prefix + \"", bsonName, ".\" + ", keyPath)
+			g.P("\t\t\t\tif v, ok := x.", fieldName, "[k]; ok {")
+			g.P("\t\t\t\t\tset[path] = v")
+			g.P("\t\t\t\t} else {")
+			g.P("\t\t\t\t\tunset[path] = \"\"")
+			g.P("\t\t\t\t}")
+			g.P("\t\t\t}")
+			g.P("\t\t}")
+		case field.Desc.IsList():
+			// 数组整体写入，避免按下标更新在字段缺失时生成对象
+			g.P("\t\tif x.", fieldName, " == nil {")
+			g.P("\t\t\tset[prefix+\"", bsonName, "\"] = ", getGoType(field), "{}")
+			g.P("\t\t} else {")
+			if needsCopy(field) {
+				generateCopyValue(g, field, "\t\t\t", "v", "x."+fieldName)
+				g.P("\t\t\tset[prefix+\"", bsonName, "\"] = v")
+			} else {
+				g.P("\t\t\tset[prefix+\"", bsonName, "\"] = x.", fieldName)
+			}
+			g.P("\t\t}")
+		case isMessageField(field):
+			// 整体替换时写入展开后的文档，不经过子对象加锁的MarshalBSON
+			g.P("\t\tif x.", fieldName, " == nil {")
+			g.P("\t\t\tset[prefix+\"", bsonName, "\"] = nil")
+			g.P("\t\t} else if x.isFieldReplaced(", constName, ") {")
+			g.P("\t\t\tset[prefix+\"", bsonName, "\"] = x.", fieldName, ".toBSON()")
+			g.P("\t\t} else {")
+			g.P("\t\t\tx.", fieldName, ".collectDirtyUpdate(prefix+\"", bsonName, ".\", set, unset, inc)")
+			g.P("\t\t}")
+		case isDecimalField(field):
+			g.P("\t\tif x.Dirty.", incrementFieldName(field), " != nil {")
+			g.P("\t\t\tinc[prefix+\"", bsonName, "\"] = *x.Dirty.", incrementFieldName(field))
+			g.P("\t\t} else {")
+			g.P("\t\t\tset[prefix+\"", bsonName, "\"] = x.", fieldName)
+			g.P("\t\t}")
+		case needsCopy(field):
+			generateCopyValue(g, field, "\t\t", "v", "x."+fieldName)
+			g.P("\t\tset[prefix+\"", bsonName, "\"] = v")
+		default:
+			g.P("\t\tset[prefix+\"", bsonName, "\"] = x.", fieldName)
+		}
+		g.P("\t}")
+	}
+	g.P("}")
+	g.P()
+}
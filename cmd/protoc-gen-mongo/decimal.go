@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// isDecimalField 判断字段是否声明了decimal
+func isDecimalField(field *protogen.Field) bool {
+	return getFieldOptions(field).GetDecimal()
+}
+
+// checkDecimal 校验decimal只用于单值的string或bytes字段，校验规则只支持required、min和max
+func checkDecimal(field *protogen.Field) error {
+	if !isDecimalField(field) {
+		return nil
+	}
+	name := field.Desc.FullName()
+	opts := getFieldOptions(field)
+	kind := field.Desc.Kind()
+	if isArrayOrMap(field) || (kind != protoreflect.StringKind && kind != protoreflect.BytesKind) {
+		return fmt.Errorf("%s: decimal requires a singular string or bytes field", name)
+	}
+	if opts.GetPrimaryKey() || hasIDType(field) {
+		return fmt.Errorf("%s: decimal cannot be combined with primary_key or id_type", name)
+	}
+	rules := getFieldRules(field)
+	if rules != nil && (rules.MinLen != nil || rules.MaxLen != nil || rules.GetPattern() != "" ||
+		rules.GetFormat() != "" || len(rules.GetIn()) > 0 || rules.MinItems != nil || rules.MaxItems != nil) {
+		return fmt.Errorf("%s: only required, min and max are supported on decimal fields", name)
+	}
+	return nil
+}
+
+// incrementFieldName 返回脏标记结构体中记录待写入增量的字段名
+func incrementFieldName(field *protogen.Field) string {
+	return field.GoName + "Increment"
+}
+
+// generateIncrementDirtyFields 在脏标记结构体中为decimal字段生成增量记录
+func generateIncrementDirtyFields(g *protogen.GeneratedFile, message *protogen.Message) {
+	for _, field := range message.Fields {
+		if isDecimalField(field) {
+			g.P("\t", incrementFieldName(field), " *", ormPackage.Ident("Decimal"), " // 只经由Inc", field.GoName, "修改时累计的增量，非nil时更新写为$inc")
+		}
+	}
+}
+
+// generateIncrementReset 生成ResetDirty中清除增量记录的代码
+func generateIncrementReset(g *protogen.GeneratedFile, message *protogen.Message) {
+	for _, field := range message.Fields {
+		if isDecimalField(field) {
+			g.P("\tx.Dirty.", incrementFieldName(field), " = nil")
+		}
+	}
+}
+
+// generateIncrementMerge 生成RestoreDirty中合并增量记录的代码，需在合并位图之前执行
+// 两边都只有增量时相加；任一边整体赋值过时写入当前值
+func generateIncrementMerge(g *protogen.GeneratedFile, message *protogen.Message, bitmapSize int) {
+	for i, field := range message.Fields {
+		if !isDecimalField(field) {
+			continue
+		}
+		name := incrementFieldName(field)
+		bitmap := "s.dirty.FieldsBitmap"
+		if bitmapSize > 1 {
+			bitmap = fmt.Sprintf("s.dirty.FieldsBitmap[%d]", i/64)
+		}
+		g.P("\tif ", bitmap, "&(1<<", i%64, ") != 0 {")
+		g.P("\t\tswitch {")
+		g.P("\t\tcase s.dirty.", name, " == nil:")
+		g.P("\t\t\tx.Dirty.", name, " = nil")
+		g.P("\t\tcase !x.isFieldDirty(", i, "):")
+		g.P("\t\t\tx.Dirty.", name, " = s.dirty.", name)
+		g.P("\t\tcase x.Dirty.", name, " != nil:")
+		g.P("\t\t\tif sum, err := s.dirty.", name, ".Add(*x.Dirty.", name, "); err == nil {")
+		g.P("\t\t\t\tx.Dirty.", name, " = &sum")
+		g.P("\t\t\t} else {")
+		g.P("\t\t\t\tx.Dirty.", name, " = nil")
+		g.P("\t\t\t}")
+		g.P("\t\t}")
+		g.P("\t}")
+	}
+}
+
+// generateIncMethod 为decimal字段生成Inc<Field>，增量累计在脏标记中，保存时写为$inc
+func generateIncMethod(g *protogen.GeneratedFile, structName string, field *protogen.Field, fieldIndex int) {
+	fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+	constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+	name := incrementFieldName(field)
+
+	g.P("// Inc", field.GoName, " 把", fieldName, "加上delta，保存时写为$inc，与其他进程的并发累加互不覆盖")
+	g.P("// 保存前被Set", field.GoName, "整体赋值过时改为写入$set；结果不能精确表示时返回orm.ErrDecimalRange且不做修改")
+	g.P("func (x *", structName, ") Inc", field.GoName, "(delta ", ormPackage.Ident("Decimal"), ") error {")
+	g.P("\tif x == nil || delta.IsZero() {")
+	g.P("\t\treturn nil")
+	g.P("\t}")
+	generateChangeEventSetup(g)
+	generateLock(g, true)
+	g.P("\tx.", internalName("EnsureDirty"), "()")
+	g.P("\tv, err := x.", fieldName, ".Add(delta)")
+	g.P("\tif err != nil {")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\tincrement := x.Dirty.", name)
+	g.P("\tswitch {")
+	g.P("\tcase !x.isFieldDirty(", fieldIndex, "):")
+	g.P("\t\tincrement = &delta")
+	g.P("\tcase increment != nil:")
+	g.P("\t\tsum, err := increment.Add(delta)")
+	g.P("\t\tif err != nil {")
+	g.P("\t\t\treturn err")
+	g.P("\t\t}")
+	g.P("\t\tincrement = &sum")
+	g.P("\t}")
+	g.P("\toldValue := x.", fieldName)
+	generateCaptureOriginal(g, "\t", constName)
+	g.P("\tif !x.isFieldDirty(", fieldIndex, ") {")
+	g.P("\t\tx.Dirty.TotalChanges++")
+	g.P("\t}")
+	g.P("\tx.setFieldDirty(", fieldIndex, ")")
+	g.P("\tx.Dirty.", name, " = increment")
+	g.P("\tx.", fieldName, " = v")
+	g.P("\tx.notifyParentDirty()")
+	generateChangeEvent(g, field, "\t", constName, "oldValue", "v")
+	g.P("\treturn nil")
+	g.P("}")
+	g.P()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateDiff 生成比较两个实例并构建增量更新文档的函数
+// 比较规则与collectDirtyUpdate一致，导入等场景可直接用于UpdateOne
+func generateDiff(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	g.P("// Diff", structName, " 比较两个", structName, "实例，返回变更的字段路径和把from更新为to的增量更新文档")
+	g.P("// 主键和版本字段不参与比较；字典按键比较，嵌套消息按子路径比较；没有差异时返回nil")
+	if threadSafe {
+		g.P("// 比较期间持有两个实例的读锁")
+	}
+	g.P("func Diff", structName, "(from, to *", structName, ") ([]string, ", bsonPackage.Ident("M"), ") {")
+	g.P("\tif from == nil {")
+	g.P("\t\tfrom = New", structName, "()")
+	g.P("\t}")
+	g.P("\tif to == nil {")
+	g.P("\t\tto = New", structName, "()")
+	g.P("\t}")
+	if threadSafe {
+		g.P("\tdefer from.rlock()()")
+		g.P("\tif to.rootLocker() != from.rootLocker() {")
+		g.P("\t\tdefer to.rlock()()")
+		g.P("\t}")
+	}
+	g.P("\tset, unset := ", bsonPackage.Ident("M"), "{}, ", bsonPackage.Ident("M"), "{}")
+	g.P("\tpaths := from.collectDiff(\"\", to, set, unset, nil)")
+	g.P("\tif len(paths) == 0 {")
+	g.P("\t\treturn nil, nil")
+	g.P("\t}")
+	g.P("\tupdate := ", bsonPackage.Ident("M"), "{}")
+	g.P("\tif len(set) > 0 {")
+	g.P("\t\tupdate[\"$set\"] = set")
+	g.P("\t}")
+	g.P("\tif len(unset) > 0 {")
+	g.P("\t\tupdate[\"$unset\"] = unset")
+	g.P("\t}")
+	g.P("\treturn paths, update")
+	g.P("}")
+	g.P()
+
+	g.P("// collectDiff 按字段顺序比较并收集$set和$unset项，返回追加后的变更路径")
+	g.P("func (x *", structName, ") collectDiff(prefix string, to *", structName, ", set, unset ", bsonPackage.Ident("M"), ", paths []string) []string {")
+	if hasPreviousNames(message) {
+		g.P("\tvar changed int")
+	}
+	for _, field := range message.Fields {
+		opts := getFieldOptions(field)
+		if opts.GetPrimaryKey() || opts.GetVersion() {
+			continue
+		}
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		bsonName := getBsonName(field)
+		fieldType := getGoType(field)
+		previousNames := opts.GetPreviousNames()
+		if len(previousNames) > 0 {
+			g.P("\tchanged = len(paths)")
+		}
+
+		switch {
+		case field.Desc.IsMap():
+			keyType, _ := getMapTypes(field)
+			keyPath := "k"
+			if keyType != "string" {
+				keyPath = g.QualifiedGoIdent(fmtPackage.Ident("Sprint")) + "(k)"
+			}
+			// 任一方为nil时整体写入，与脏标记更新中nil字典的处理一致
+			g.P("\tif x.", fieldName, " == nil || to.", fieldName, " == nil {")
+			g.P("\t\tif len(x.", fieldName, ") != 0 || len(to.", fieldName, ") != 0 {")
+			g.P("\t\t\tif to.", fieldName, " == nil {")
+			g.P("\t\t\t\tset[prefix+\"", bsonName, "\"] = ", fieldType, "{}")
+			g.P("\t\t\t} else {")
+			g.P("\t\t\t\tset[prefix+\"", bsonName, "\"] = ", cloneValueExpr(g, field, "to."+fieldName))
+			g.P("\t\t\t}")
+			g.P("\t\t\tpaths = append(paths, prefix+\"", bsonName, "\")")
+			g.P("\t\t}")
+			if keyType == "string" {
+				// 键不能作为点分路径的一段时整体写入
+				g.P("\t} else if !", ormPackage.Ident("SafeMapKeys"), "(x.", fieldName, ") || !", ormPackage.Ident("SafeMapKeys"), "(to.", fieldName, ") {")
+				g.P("\t\tif !", reflectPackage.Ident("DeepEqual"), "(x.", fieldName, ", to.", fieldName, ") {")
+				g.P("\t\t\tset[prefix+\"", bsonName, "\"] = ", cloneValueExpr(g, field, "to."+fieldName))
+				g.P("\t\t\tpaths = append(paths, prefix+\"", bsonName, "\")")
+				g.P("\t\t}")
+			}
+			g.P("\t} else {")
+			g.P("\t\tfor _, k := range ", sortedKeysExpr(g, keyType, "to."+fieldName), " {")
+			if keyType == "bool" {
+				g.P("\t\t\tif _, ok := to.", fieldName, "[k]; !ok {")
+				g.P("\t\t\t\tcontinue")
+				g.P("\t\t\t}")
+			}
+			g.P("\t\t\tif v, ok := x.", fieldName, "[k]; !ok || !", reflectPackage.Ident("DeepEqual"), "(v, to.", fieldName, "[k]) {")
+			g.P("\t\t\t\tpath := prefix + \"", bsonName, ".\" + ", keyPath)
+			g.P("\t\t\t\tset[path] = to.", fieldName, "[k]")
+			g.P("\t\t\t\tpaths = append(paths, path)")
+			g.P("\t\t\t}")
+			g.P("\t\t}")
+			g.P("\t\tfor _, k := range ", sortedKeysExpr(g, keyType, "x."+fieldName), " {")
+			if keyType == "bool" {
+				g.P("\t\t\tif _, ok := x.", fieldName, "[k]; !ok {")
+				g.P("\t\t\t\tcontinue")
+				g.P("\t\t\t}")
+			}
+			g.P("\t\t\tif _, ok := to.", fieldName, "[k]; !ok {")
+			g.P("\t\t\t\tpath := prefix + \"", bsonName, ".\" + ", keyPath)
+			g.P("\t\t\t\tunset[path] = \"\"")
+			g.P("\t\t\t\tpaths = append(paths, path)")
+			g.P("\t\t\t}")
+			g.P("\t\t}")
+			g.P("\t}")
+		case field.Desc.IsList():
+			// nil和空数组在文档中都写成[]，视为相同
+			g.P("\tif (len(x.", fieldName, ") != 0 || len(to.", fieldName, ") != 0) && !", reflectPackage.Ident("DeepEqual"), "(x.", fieldName, ", to.", fieldName, ") {")
+			g.P("\t\tif to.", fieldName, " == nil {")
+			g.P("\t\t\tset[prefix+\"", bsonName, "\"] = ", fieldType, "{}")
+			g.P("\t\t} else {")
+			g.P("\t\t\tset[prefix+\"", bsonName, "\"] = ", cloneValueExpr(g, field, "to."+fieldName))
+			g.P("\t\t}")
+			g.P("\t\tpaths = append(paths, prefix+\"", bsonName, "\")")
+			g.P("\t}")
+		case isMessageField(field):
+			g.P("\tif x.", fieldName, " == nil || to.", fieldName, " == nil {")
+			g.P("\t\tif x.", fieldName, " != to.", fieldName, " {")
+			g.P("\t\t\tif to.", fieldName, " == nil {")
+			g.P("\t\t\t\tset[prefix+\"", bsonName, "\"] = nil")
+			g.P("\t\t\t} else {")
+			g.P("\t\t\t\tset[prefix+\"", bsonName, "\"] = to.", fieldName, ".toBSON()")
+			g.P("\t\t\t}")
+			g.P("\t\t\tpaths = append(paths, prefix+\"", bsonName, "\")")
+			g.P("\t\t}")
+			g.P("\t} else {")
+			g.P("\t\tpaths = x.", fieldName, ".collectDiff(prefix+\"", bsonName, ".\", to.", fieldName, ", set, unset, paths)")
+			g.P("\t}")
+		case isDecimalField(field):
+			g.P("\tif !x.", fieldName, ".Equal(to.", fieldName, ") {")
+			g.P("\t\tset[prefix+\"", bsonName, "\"] = to.", fieldName)
+			g.P("\t\tpaths = append(paths, prefix+\"", bsonName, "\")")
+			g.P("\t}")
+		default:
+			g.P("\tif !", reflectPackage.Ident("DeepEqual"), "(x.", fieldName, ", to.", fieldName, ") {")
+			g.P("\t\tset[prefix+\"", bsonName, "\"] = ", cloneValueExpr(g, field, "to."+fieldName))
+			g.P("\t\tpaths = append(paths, prefix+\"", bsonName, "\")")
+			g.P("\t}")
+		}
+		if len(previousNames) > 0 {
+			// 与collectDirtyUpdate一致，字段有变更时删除旧键名
+			g.P("\tif len(paths) > changed {")
+			for _, name := range previousNames {
+				g.P("\t\tunset[prefix+\"", name, "\"] = \"\"")
+			}
+			g.P("\t}")
+		}
+	}
+	g.P("\treturn paths")
+	g.P("}")
+	g.P()
+}
+
+// sortedKeysExpr 返回按键排序遍历字典的表达式，使变更路径的顺序稳定；bool键不可排序，按false、true遍历
+func sortedKeysExpr(g *protogen.GeneratedFile, keyType, expr string) string {
+	if keyType == "bool" {
+		return "[]bool{false, true}"
+	}
+	return g.QualifiedGoIdent(slicesPackage.Ident("Sorted")) + "(" + g.QualifiedGoIdent(mapsPackage.Ident("Keys")) + "(" + expr + "))"
+}
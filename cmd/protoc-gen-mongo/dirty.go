@@ -12,38 +12,56 @@ func generateDirtyMethods(g *protogen.GeneratedFile, message *protogen.Message,
 
 	// 重置脏标记
 	g.P("// ResetDirty 重置所有脏标记")
-	g.P("func (x *", structName, ") ResetDirty() {")
-	g.P("\tif x == nil || x.Dirty == nil {")
-	g.P("\t\treturn")
-	g.P("\t}")
-	g.P("\tx.Dirty.TotalChanges = 0")
-
-	// 重置位图
-	if bitmapSize == 1 {
-		g.P("\tx.Dirty.FieldsBitmap = 0")
-	} else {
-		g.P("\tfor i := range x.Dirty.FieldsBitmap {")
-		g.P("\t\tx.Dirty.FieldsBitmap[i] = 0")
+	generateLockedMethod(g, structName, "ResetDirty", "", "", "", true, func() {
+		g.P("\tif x == nil || x.Dirty == nil {")
+		g.P("\t\treturn")
 		g.P("\t}")
-	}
+		g.P("\tx.Dirty.TotalChanges = 0")
 
-	// 重置数组和字典的元素跟踪
-	for _, field := range message.Fields {
-		if isArrayOrMap(field) {
-			publicFieldName := strings.Title(strings.ToLower(field.GoName[:1]) + field.GoName[1:])
-			g.P("\tx.Dirty.", publicFieldName, "Elements = make(map[interface{}]bool)")
+		// 重置位图
+		if bitmapSize == 1 {
+			g.P("\tx.Dirty.FieldsBitmap = 0")
+			g.P("\tx.Dirty.ReplacedBitmap = 0")
+		} else {
+			g.P("\tfor i := range x.Dirty.FieldsBitmap {")
+			g.P("\t\tx.Dirty.FieldsBitmap[i] = 0")
+			g.P("\t\tx.Dirty.ReplacedBitmap[i] = 0")
+			g.P("\t}")
+		}
+
+		generateOriginalReset(g, structName, bitmapSize)
+		generateIncrementReset(g, message)
+		generateUpgradedReset(g, message)
+
+		// 重置数组和字典的元素跟踪
+		for _, field := range message.Fields {
+			if isArrayOrMap(field) {
+				publicFieldName := strings.Title(strings.ToLower(field.GoName[:1]) + field.GoName[1:])
+				g.P("\tx.Dirty.", publicFieldName, "Elements = make(map[interface{}]bool)")
+			}
 		}
-	}
-	g.P("}")
-	g.P()
+
+		// 递归重置嵌套消息，保存后子对象不应残留脏标记
+		for _, field := range message.Fields {
+			if isMessageField(field) {
+				fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+				g.P("\tx.", fieldName, ".", internalName("ResetDirty"), "()")
+			}
+		}
+	})
 
 	// 检查是否有脏数据
 	g.P("// IsDirty 检查是否有脏数据")
 	g.P("func (x *", structName, ") IsDirty() bool {")
+	generateLock(g, false)
 	g.P("\tif x == nil || x.Dirty == nil {")
 	g.P("\t\treturn false")
 	g.P("\t}")
-	g.P("\treturn x.Dirty.TotalChanges > 0")
+	if getMessageOptions(message).GetSchemaVersion() > 0 {
+		g.P("\treturn x.Dirty.TotalChanges > 0 || x.Dirty.SchemaUpgraded")
+	} else {
+		g.P("\treturn x.Dirty.TotalChanges > 0")
+	}
 	g.P("}")
 	g.P()
 
@@ -53,6 +71,7 @@ func generateDirtyMethods(g *protogen.GeneratedFile, message *protogen.Message,
 
 		g.P("// Is", publicName, "Dirty 检查", publicName, "字段是否有变更")
 		g.P("func (x *", structName, ") Is", publicName, "Dirty() bool {")
+		generateLock(g, false)
 		g.P("\treturn x.isFieldDirty(", i, ")")
 		g.P("}")
 		g.P()
@@ -61,6 +80,7 @@ func generateDirtyMethods(g *protogen.GeneratedFile, message *protogen.Message,
 	// 生成获取脏字段数量的方法
 	g.P("// GetDirtyFieldCount 获取脏字段数量")
 	g.P("func (x *", structName, ") GetDirtyFieldCount() int {")
+	generateLock(g, false)
 	g.P("\tif x == nil || x.Dirty == nil {")
 	g.P("\t\treturn 0")
 	g.P("\t}")
@@ -70,17 +90,16 @@ func generateDirtyMethods(g *protogen.GeneratedFile, message *protogen.Message,
 
 	// 生成获取所有脏字段索引的方法
 	g.P("// GetDirtyFieldIndexes 获取所有脏字段的索引")
-	g.P("func (x *", structName, ") GetDirtyFieldIndexes() []int {")
-	g.P("\tif x == nil || x.Dirty == nil {")
-	g.P("\t\treturn nil")
-	g.P("\t}")
-	g.P("\tvar indexes []int")
-	g.P("\tfor i := 0; i < ", fieldCount, "; i++ {")
-	g.P("\t\tif x.isFieldDirty(i) {")
-	g.P("\t\t\tindexes = append(indexes, i)")
-	g.P("\t\t}")
-	g.P("\t}")
-	g.P("\treturn indexes")
-	g.P("}")
-	g.P()
+	generateLockedMethod(g, structName, "GetDirtyFieldIndexes", "", "", "[]int", false, func() {
+		g.P("\tif x == nil || x.Dirty == nil {")
+		g.P("\t\treturn nil")
+		g.P("\t}")
+		g.P("\tvar indexes []int")
+		g.P("\tfor i := 0; i < ", fieldCount, "; i++ {")
+		g.P("\t\tif x.isFieldDirty(i) {")
+		g.P("\t\t\tindexes = append(indexes, i)")
+		g.P("\t\t}")
+		g.P("\t}")
+		g.P("\treturn indexes")
+	})
 }
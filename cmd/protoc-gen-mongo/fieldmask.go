@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateFieldMaskMethods 生成Clone、ApplyFieldMask、DirtyFieldMask和Projection
+// 字段掩码路径使用proto字段名，按点分隔进入嵌套消息，字典和数组只能整体指定
+func generateFieldMaskMethods(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	generateClone(g, message, structName)
+	generateBSONFieldPath(g, message, structName)
+	generateLegacyFieldPaths(g, message, structName)
+	generateApplyFieldMask(g, message, structName)
+	generateDirtyFieldMask(g, message, structName)
+	generateProjection(g, message, structName)
+}
+
+// generateClone 生成深拷贝方法，副本没有脏标记、父对象和监听器
+func generateClone(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	g.P("// Clone 深拷贝字段值，返回的副本没有脏标记，也不挂在任何父对象下")
+	generateLockedMethod(g, structName, "Clone", "", "", "*"+structName, false, func() {
+		g.P("\tif x == nil {")
+		g.P("\t\treturn nil")
+		g.P("\t}")
+		g.P("\tc := New", structName, "()")
+		for _, field := range message.Fields {
+			fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+			if isMessageField(field) {
+				constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+				g.P("\tif x.", fieldName, " != nil {")
+				g.P("\t\tc.", fieldName, " = x.", fieldName, ".", internalName("Clone"), "()")
+				g.P("\t\tc.", fieldName, ".", internalName("SetParentNotifier"), "(c, ", constName, ")")
+				g.P("\t}")
+				continue
+			}
+			g.P("\tc.", fieldName, " = ", cloneValueExpr(g, field, "x."+fieldName))
+		}
+		g.P("\tc.partial = x.partial")
+		g.P("\tc.loadedBitmap = x.loadedBitmap")
+		g.P("\treturn c")
+	})
+}
+
+// generateBSONFieldPath 生成把字段掩码路径转换为BSON点分路径的方法，同时用于校验路径
+func generateBSONFieldPath(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	hasMessage := false
+	for _, field := range message.Fields {
+		if isMessageField(field) {
+			hasMessage = true
+		}
+	}
+
+	g.P("// bsonFieldPath 把字段掩码路径转换为BSON文档中的路径，路径不存在时返回false")
+	g.P("// 只依赖类型信息，可以在nil上调用")
+	g.P("func (*", structName, ") bsonFieldPath(path string) (string, bool) {")
+	if hasMessage {
+		g.P("\tname, rest, nested := ", stringsPackage.Ident("Cut"), "(path, \".\")")
+	} else {
+		g.P("\tname, _, nested := ", stringsPackage.Ident("Cut"), "(path, \".\")")
+	}
+	g.P("\tswitch name {")
+	for _, field := range message.Fields {
+		bsonName := getBsonName(field)
+		g.P("\tcase \"", getFieldName(field), "\":")
+		if isMessageField(field) {
+			g.P("\t\tif !nested {")
+			g.P("\t\t\treturn \"", bsonName, "\", true")
+			g.P("\t\t}")
+			g.P("\t\tsub, ok := (*", field.Message.GoIdent.GoName, ")(nil).bsonFieldPath(rest)")
+			g.P("\t\treturn \"", bsonName, ".\" + sub, ok")
+			continue
+		}
+		g.P("\t\treturn \"", bsonName, "\", !nested")
+	}
+	g.P("\t}")
+	g.P("\treturn \"\", false")
+	g.P("}")
+	g.P()
+}
+
+// generateLegacyFieldPaths 为声明了previous_names的消息生成字段掩码路径对应的旧键名路径
+// 嵌套消息的旧路径包括当前键名下子字段的旧键名，以及父字段旧键名下的全部子路径
+func generateLegacyFieldPaths(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	if !hasMigrations(message) {
+		return
+	}
+	hasMessage := false
+	for _, field := range message.Fields {
+		if isMessageField(field) && (hasMigrations(field.Message) || len(getFieldOptions(field).GetPreviousNames()) > 0) {
+			hasMessage = true
+		}
+	}
+	g.P("// legacyFieldPaths 返回已校验的字段掩码路径可能仍在使用的旧BSON路径，只依赖类型信息，可以在nil上调用")
+	g.P("func (*", structName, ") legacyFieldPaths(path string) []string {")
+	if hasMessage {
+		g.P("\tname, rest, nested := ", stringsPackage.Ident("Cut"), "(path, \".\")")
+	} else {
+		g.P("\tname, _, _ := ", stringsPackage.Ident("Cut"), "(path, \".\")")
+	}
+	g.P("\tswitch name {")
+	for _, field := range message.Fields {
+		names := getFieldOptions(field).GetPreviousNames()
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = strconv.Quote(name)
+		}
+		if !isMessageField(field) {
+			if len(names) > 0 {
+				g.P("\tcase \"", getFieldName(field), "\":")
+				g.P("\t\treturn []string{", strings.Join(quoted, ", "), "}")
+			}
+			continue
+		}
+		childMigrations := hasMigrations(field.Message)
+		if len(names) == 0 && !childMigrations {
+			continue
+		}
+		child := field.Message.GoIdent.GoName
+		g.P("\tcase \"", getFieldName(field), "\":")
+		g.P("\t\tif !nested {")
+		if len(names) > 0 {
+			g.P("\t\t\treturn []string{", strings.Join(quoted, ", "), "}")
+		} else {
+			g.P("\t\t\treturn nil")
+		}
+		g.P("\t\t}")
+		g.P("\t\tvar paths []string")
+		if childMigrations {
+			g.P("\t\tlegacy := (*", child, ")(nil).legacyFieldPaths(rest)")
+			g.P("\t\tfor _, sub := range legacy {")
+			g.P("\t\t\tpaths = append(paths, \"", getBsonName(field), ".\"+sub)")
+			g.P("\t\t}")
+		}
+		if len(names) > 0 {
+			g.P("\t\tcurrent, _ := (*", child, ")(nil).bsonFieldPath(rest)")
+			if childMigrations {
+				g.P("\t\tfor _, sub := range append([]string{current}, legacy...) {")
+			} else {
+				g.P("\t\tfor _, sub := range []string{current} {")
+			}
+			prefixed := make([]string, len(names))
+			for i, name := range names {
+				prefixed[i] = strconv.Quote(name+".") + "+sub"
+			}
+			g.P("\t\t\tpaths = append(paths, ", strings.Join(prefixed, ", "), ")")
+			g.P("\t\t}")
+		}
+		g.P("\t\treturn paths")
+	}
+	g.P("\t}")
+	g.P("\treturn nil")
+	g.P("}")
+	g.P()
+}
+
+// generateApplyFieldMask 生成按字段掩码从另一个实例复制字段的方法
+func generateApplyFieldMask(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	fieldMask := g.QualifiedGoIdent(fieldmaskPackage.Ident("FieldMask"))
+
+	g.P("// ApplyFieldMask 把mask中的路径从src复制到当前对象，通过Setter赋值，值改变的字段被标记为脏")
+	g.P("// 路径全部校验通过后才开始复制；嵌套路径在当前对象的子消息为nil时先创建子消息")
+	g.P("func (x *", structName, ") ApplyFieldMask(src *", structName, ", mask *", fieldMask, ") error {")
+	g.P("\tif x == nil {")
+	g.P("\t\treturn nil")
+	g.P("\t}")
+	g.P("\tfor _, path := range mask.GetPaths() {")
+	g.P("\t\tif _, ok := x.bsonFieldPath(path); !ok {")
+	g.P("\t\t\treturn ", fmtPackage.Ident("Errorf"), "(\"", message.Desc.FullName(), ": invalid field mask path %q\", path)")
+	g.P("\t\t}")
+	g.P("\t}")
+	g.P("\t// 先复制一份src，赋值时子消息直接转移给当前对象，不会与src共享")
+	g.P("\tsrc = src.Clone()")
+	g.P("\tfor _, path := range mask.GetPaths() {")
+	g.P("\t\tx.applyFieldPath(src, path)")
+	g.P("\t}")
+	g.P("\treturn nil")
+	g.P("}")
+	g.P()
+
+	hasMessage := false
+	for _, field := range message.Fields {
+		if isMessageField(field) {
+			hasMessage = true
+		}
+	}
+	g.P("// applyFieldPath 复制单个已校验的路径，src为nil时写入零值")
+	g.P("func (x *", structName, ") applyFieldPath(src *", structName, ", path string) {")
+	if hasMessage {
+		g.P("\tname, rest, nested := ", stringsPackage.Ident("Cut"), "(path, \".\")")
+	} else {
+		g.P("\tname, _, _ := ", stringsPackage.Ident("Cut"), "(path, \".\")")
+	}
+	g.P("\tswitch name {")
+	for _, field := range message.Fields {
+		g.P("\tcase \"", getFieldName(field), "\":")
+		if isMessageField(field) {
+			g.P("\t\tif !nested {")
+			g.P("\t\t\tx.Set", field.GoName, "(src.Get", field.GoName, "())")
+			g.P("\t\t\treturn")
+			g.P("\t\t}")
+			g.P("\t\tif x.Get", field.GoName, "() == nil {")
+			g.P("\t\t\tx.Set", field.GoName, "(New", field.Message.GoIdent.GoName, "())")
+			g.P("\t\t}")
+			g.P("\t\tx.Get", field.GoName, "().applyFieldPath(src.Get", field.GoName, "(), rest)")
+			continue
+		}
+		g.P("\t\tx.Set", field.GoName, "(src.Get", field.GoName, "())")
+	}
+	g.P("\t}")
+	g.P("}")
+	g.P()
+}
+
+// generateDirtyFieldMask 生成把脏字段转换为字段掩码的方法
+func generateDirtyFieldMask(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	fieldMask := g.QualifiedGoIdent(fieldmaskPackage.Ident("FieldMask"))
+
+	g.P("// DirtyFieldMask 返回当前脏字段对应的字段掩码")
+	g.P("// 嵌套消息只修改了内部字段时展开为子路径，被整体替换时使用字段本身的路径")
+	g.P("func (x *", structName, ") DirtyFieldMask() *", fieldMask, " {")
+	generateLock(g, false)
+	g.P("\treturn &", fieldMask, "{Paths: x.collectDirtyPaths(\"\", nil)}")
+	g.P("}")
+	g.P()
+
+	g.P("// collectDirtyPaths 按字段顺序收集脏字段的掩码路径")
+	g.P("func (x *", structName, ") collectDirtyPaths(prefix string, paths []string) []string {")
+	g.P("\tif x == nil || x.Dirty == nil {")
+	g.P("\t\treturn paths")
+	g.P("\t}")
+	for _, field := range message.Fields {
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+		name := getFieldName(field)
+		g.P("\tif x.isFieldDirty(", constName, ") {")
+		if isMessageField(field) {
+			g.P("\t\tif x.isFieldReplaced(", constName, ") || x.", fieldName, " == nil {")
+			g.P("\t\t\tpaths = append(paths, prefix+\"", name, "\")")
+			g.P("\t\t} else {")
+			g.P("\t\t\tpaths = x.", fieldName, ".collectDirtyPaths(prefix+\"", name, ".\", paths)")
+			g.P("\t\t}")
+		} else {
+			g.P("\t\tpaths = append(paths, prefix+\"", name, "\")")
+		}
+		g.P("\t}")
+	}
+	g.P("\treturn paths")
+	g.P("}")
+	g.P()
+}
+
+// generateProjection 生成把字段掩码转换为查询投影的函数
+func generateProjection(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	fieldMask := g.QualifiedGoIdent(fieldmaskPackage.Ident("FieldMask"))
+
+	g.P("// ", structName, "Projection 把字段掩码转换为MongoDB查询投影，空掩码返回nil表示查询全部字段")
+	g.P("// 掩码先规范化，去掉被父路径覆盖的子路径，避免投影路径冲突；_id按MongoDB默认规则返回")
+	schemaVersion := getMessageOptions(message).GetSchemaVersion() > 0
+	if schemaVersion {
+		g.P("// 总是投影_v，解码时据此判断文档是否已是当前结构版本")
+	}
+	migrations := hasMigrations(message)
+	if migrations {
+		g.P("// 声明了previous_names的字段同时投影旧键名，尚未迁移的文档也能加载这些字段")
+	}
+	g.P("func ", structName, "Projection(mask *", fieldMask, ") (", bsonPackage.Ident("D"), ", error) {")
+	g.P("\tif len(mask.GetPaths()) == 0 {")
+	g.P("\t\treturn nil, nil")
+	g.P("\t}")
+	g.P("\tnormalized := &", fieldMask, "{Paths: ", slicesPackage.Ident("Clone"), "(mask.GetPaths())}")
+	g.P("\tnormalized.Normalize()")
+	g.P("\tprojection := make(", bsonPackage.Ident("D"), ", 0, len(normalized.Paths))")
+	g.P("\tfor _, path := range normalized.Paths {")
+	g.P("\t\tbsonPath, ok := (*", structName, ")(nil).bsonFieldPath(path)")
+	g.P("\t\tif !ok {")
+	g.P("\t\t\treturn nil, ", fmtPackage.Ident("Errorf"), "(\"", message.Desc.FullName(), ": invalid field mask path %q\", path)")
+	g.P("\t\t}")
+	g.P("\t\tprojection = append(projection, ", bsonPackage.Ident("E"), "{Key: bsonPath, Value: 1})")
+	if migrations {
+		g.P("\t\tfor _, legacy := range (*", structName, ")(nil).legacyFieldPaths(path) {")
+		g.P("\t\t\tprojection = append(projection, ", bsonPackage.Ident("E"), "{Key: legacy, Value: 1})")
+		g.P("\t\t}")
+	}
+	g.P("\t}")
+	if schemaVersion {
+		g.P("\tprojection = append(projection, ", bsonPackage.Ident("E"), "{Key: ", ormPackage.Ident("SchemaVersionField"), ", Value: 1})")
+	}
+	g.P("\treturn projection, nil")
+	g.P("}")
+	g.P()
+}
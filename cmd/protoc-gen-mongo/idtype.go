@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"DB/orm/ormpb"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// idTypeIdent 返回声明了id_type的字段在生成结构体中的类型
+func idTypeIdent(field *protogen.Field) (protogen.GoIdent, bool) {
+	switch getFieldOptions(field).GetIdType() {
+	case ormpb.IdType_OBJECT_ID:
+		return primitivePackage.Ident("ObjectID"), true
+	case ormpb.IdType_UUID:
+		return ormPackage.Ident("UUID"), true
+	}
+	return protogen.GoIdent{}, false
+}
+
+// hasIDType 判断字段是否声明了id_type
+func hasIDType(field *protogen.Field) bool {
+	_, ok := idTypeIdent(field)
+	return ok
+}
+
+// checkIDType 校验id_type只用于单值的string或bytes字段，校验规则只支持required
+func checkIDType(field *protogen.Field) error {
+	if !hasIDType(field) {
+		return nil
+	}
+	name := field.Desc.FullName()
+	kind := field.Desc.Kind()
+	if isArrayOrMap(field) || (kind != protoreflect.StringKind && kind != protoreflect.BytesKind) {
+		return fmt.Errorf("%s: id_type requires a singular string or bytes field", name)
+	}
+	rules := getFieldRules(field)
+	if rules != nil && (hasValueRules(rules) || rules.MinItems != nil || rules.MaxItems != nil) {
+		return fmt.Errorf("%s: only required is supported on id_type fields", name)
+	}
+	return nil
+}
+
+// generateEnsurePrimaryKey 主键声明了id_type时生成EnsurePrimaryKey，Insert在插入前调用
+func generateEnsurePrimaryKey(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	pkField := getPrimaryKeyField(message)
+	ident, ok := idTypeIdent(pkField)
+	if !ok {
+		return
+	}
+	pkName := strings.ToLower(pkField.GoName[:1]) + pkField.GoName[1:]
+	g.P("// EnsurePrimaryKey 主键为零值时生成新的", ident.GoName, "，不产生脏标记；Insert在调用BeforeInsert之前调用")
+	g.P("func (x *", structName, ") EnsurePrimaryKey() {")
+	generateLock(g, true)
+	g.P("\tif x.", pkName, ".IsZero() {")
+	g.P("\t\tx.", pkName, " = ", ident.GoImportPath.Ident("New"+ident.GoName), "()")
+	g.P("\t}")
+	g.P("}")
+	g.P()
+}
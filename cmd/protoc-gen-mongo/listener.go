@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateFieldListenerType 生成字段变更监听器类型，同一文件中的消息共用
+func generateFieldListenerType(g *protogen.GeneratedFile) {
+	g.P("// fieldListener 字段变更监听器，fieldIndex为-1时监听所有字段")
+	g.P("type fieldListener struct {")
+	g.P("\tfieldIndex int")
+	g.P("\tfn         func(fieldIndex int, oldValue, newValue interface{})")
+	g.P("}")
+	g.P()
+}
+
+// generateListenerMethods 生成OnFieldChanged订阅方法以及每个字段的强类型订阅方法
+func generateListenerMethods(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	g.P("// OnFieldChanged 订阅任意字段的变更，回调在Set/Add/Remove等操作实际修改字段后调用")
+	g.P("// 嵌套消息内部的修改只通知子对象自己的监听器；返回取消订阅的函数")
+	g.P("func (x *", structName, ") OnFieldChanged(fn func(fieldIndex int, oldValue, newValue interface{})) func() {")
+	g.P("\treturn x.addFieldListener(-1, fn)")
+	g.P("}")
+	g.P()
+
+	for _, field := range message.Fields {
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		fieldType := getGoType(field)
+		constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+
+		g.P("// On", field.GoName, "Changed 订阅", fieldName, "字段的变更，返回取消订阅的函数")
+		g.P("func (x *", structName, ") On", field.GoName, "Changed(fn func(oldValue, newValue ", fieldType, ")) func() {")
+		g.P("\tif fn == nil {")
+		g.P("\t\treturn func() {}")
+		g.P("\t}")
+		g.P("\treturn x.addFieldListener(", constName, ", func(_ int, oldValue, newValue interface{}) {")
+		g.P("\t\tfn(oldValue.(", fieldType, "), newValue.(", fieldType, "))")
+		g.P("\t})")
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// addFieldListener 注册监听器并返回取消订阅的函数")
+	g.P("func (x *", structName, ") addFieldListener(fieldIndex int, fn func(fieldIndex int, oldValue, newValue interface{})) func() {")
+	g.P("\tif x == nil || fn == nil {")
+	g.P("\t\treturn func() {}")
+	g.P("\t}")
+	generateLock(g, true)
+	g.P("\tlistener := &fieldListener{fieldIndex: fieldIndex, fn: fn}")
+	g.P("\tx.listeners = append(x.listeners, listener)")
+	g.P("\treturn func() { x.removeFieldListener(listener) }")
+	g.P("}")
+	g.P()
+
+	g.P("// removeFieldListener 取消订阅，重新分配切片，正在进行的通知不受影响")
+	g.P("func (x *", structName, ") removeFieldListener(listener *fieldListener) {")
+	generateLock(g, true)
+	g.P("\tfor i, l := range x.listeners {")
+	g.P("\t\tif l == listener {")
+	g.P("\t\t\tx.listeners = append(x.listeners[:i:i], x.listeners[i+1:]...)")
+	g.P("\t\t\treturn")
+	g.P("\t\t}")
+	g.P("\t}")
+	g.P("}")
+	g.P()
+
+	if threadSafe {
+		g.P("// fieldChangedEvent 持锁时记录当前监听器，返回解锁后调用的通知函数，回调中可以再次访问实体")
+		g.P("func (x *", structName, ") fieldChangedEvent(fieldIndex int, oldValue, newValue interface{}) func() {")
+		g.P("\tlisteners := x.listeners")
+		g.P("\treturn func() {")
+		g.P("\t\tfor _, l := range listeners {")
+		g.P("\t\t\tif l.fieldIndex < 0 || l.fieldIndex == fieldIndex {")
+		g.P("\t\t\t\tl.fn(fieldIndex, oldValue, newValue)")
+		g.P("\t\t\t}")
+		g.P("\t\t}")
+		g.P("\t}")
+		g.P("}")
+		g.P()
+		return
+	}
+
+	g.P("// emitFieldChanged 通知字段变更监听器")
+	g.P("func (x *", structName, ") emitFieldChanged(fieldIndex int, oldValue, newValue interface{}) {")
+	g.P("\tfor _, l := range x.listeners {")
+	g.P("\t\tif l.fieldIndex < 0 || l.fieldIndex == fieldIndex {")
+	g.P("\t\t\tl.fn(fieldIndex, oldValue, newValue)")
+	g.P("\t\t}")
+	g.P("\t}")
+	g.P("}")
+	g.P()
+}
+
+// generateChangeEventSetup 线程安全模式下在加锁前声明通知函数，解锁后再调用监听器，避免回调中访问实体时死锁
+func generateChangeEventSetup(g *protogen.GeneratedFile) {
+	if !threadSafe {
+		return
+	}
+	g.P("\tvar emit func()")
+	g.P("\tdefer func() {")
+	g.P("\t\tif emit != nil {")
+	g.P("\t\t\temit()")
+	g.P("\t\t}")
+	g.P("\t}()")
+}
+
+// generateChangeEvent 生成字段变更后通知监听器的代码，没有监听器时不复制字段值
+func generateChangeEvent(g *protogen.GeneratedFile, field *protogen.Field, indent, constName, oldValue, newValue string) {
+	g.P(indent, "if len(x.listeners) > 0 {")
+	if threadSafe {
+		// 通知在解锁后执行，传出的值不能与实体共享底层存储
+		g.P(indent, "\temit = x.fieldChangedEvent(", constName, ", ", oldValue, ", ", cloneValueExpr(g, field, newValue), ")")
+	} else {
+		g.P(indent, "\tx.emitFieldChanged(", constName, ", ", oldValue, ", ", newValue, ")")
+	}
+	g.P(indent, "}")
+}
+
+// generateOldValueCapture 原地修改数组或字典前保存旧值副本，供监听器比较
+func generateOldValueCapture(g *protogen.GeneratedFile, field *protogen.Field, fieldName string) {
+	g.P("\tvar oldValue ", getGoType(field))
+	g.P("\tif len(x.listeners) > 0 {")
+	g.P("\t\toldValue = ", cloneValueExpr(g, field, "x."+fieldName))
+	g.P("\t}")
+}
+
+// cloneValueExpr 返回复制字段值的表达式，只有数组、字典和bytes需要复制
+func cloneValueExpr(g *protogen.GeneratedFile, field *protogen.Field, expr string) string {
+	switch {
+	case field.Desc.IsMap():
+		return g.QualifiedGoIdent(mapsPackage.Ident("Clone")) + "(" + expr + ")"
+	case field.Desc.IsList() || getGoType(field) == "[]byte":
+		return g.QualifiedGoIdent(slicesPackage.Ident("Clone")) + "(" + expr + ")"
+	}
+	return expr
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// schemaLockFile 锁文件内容，按proto文件路径记录已发布的字段布局
+type schemaLockFile struct {
+	Files map[string]*lockedFile `json:"files"`
+}
+
+type lockedFile struct {
+	Messages []*lockedMessage `json:"messages"`
+}
+
+type lockedMessage struct {
+	Name   string         `json:"name"`
+	Fields []*lockedField `json:"fields"`
+}
+
+type lockedField struct {
+	Name     string `json:"name"`
+	Number   int32  `json:"number"`
+	Type     string `json:"type"`
+	BsonName string `json:"bson_name"`
+}
+
+// checkSchemaLock 把本次生成的文件与锁文件比较，有不兼容的变更时返回报告
+// 没有不兼容的变更或指定了update_schema_lock时把当前布局写回锁文件，锁文件不存在时创建
+func checkSchemaLock(gen *protogen.Plugin) error {
+	lock := &schemaLockFile{}
+	data, err := os.ReadFile(schemaLock)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, lock); err != nil {
+			return fmt.Errorf("schema lock %s: %v", schemaLock, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	updated := &schemaLockFile{Files: map[string]*lockedFile{}}
+	for path, file := range lock.Files {
+		updated.Files[path] = file
+	}
+	var problems []string
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		if locked, ok := lock.Files[f.Desc.Path()]; ok {
+			problems = append(problems, compareLockedFile(f, locked)...)
+		}
+		updated.Files[f.Desc.Path()] = lockFile(f)
+	}
+	if len(problems) > 0 && !updateSchemaLock {
+		return fmt.Errorf("schema lock %s: %d breaking change(s):\n  %s\nmigrate existing documents, then run with update_schema_lock=true to accept them",
+			schemaLock, len(problems), strings.Join(problems, "\n  "))
+	}
+
+	// 内容不变时不重写，忽略Windows检出时的CRLF换行
+	readErr := err
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false) // 类型名中的map<...>保持原样便于审查
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(updated); err != nil {
+		return err
+	}
+	if readErr == nil && bytes.Equal(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")), out.Bytes()) {
+		return nil
+	}
+	return os.WriteFile(schemaLock, out.Bytes(), 0o644)
+}
+
+// lockFile 记录文件中各消息当前的字段布局
+func lockFile(file *protogen.File) *lockedFile {
+	locked := &lockedFile{}
+	for _, message := range file.Messages {
+		m := &lockedMessage{Name: string(message.Desc.FullName())}
+		for _, field := range message.Fields {
+			m.Fields = append(m.Fields, &lockedField{
+				Name:     getFieldName(field),
+				Number:   int32(field.Desc.Number()),
+				Type:     lockTypeName(field),
+				BsonName: getBsonName(field),
+			})
+		}
+		locked.Messages = append(locked.Messages, m)
+	}
+	return locked
+}
+
+// lockTypeName 返回字段类型的描述，数组和字典带上元素类型，消息和枚举使用全名，声明了id_type或decimal时带上存储类型
+func lockTypeName(field *protogen.Field) string {
+	kindName := func(fd protoreflect.FieldDescriptor) string {
+		switch fd.Kind() {
+		case protoreflect.MessageKind, protoreflect.GroupKind:
+			return string(fd.Message().FullName())
+		case protoreflect.EnumKind:
+			return string(fd.Enum().FullName())
+		}
+		return fd.Kind().String()
+	}
+	switch {
+	case hasIDType(field):
+		return kindName(field.Desc) + " as " + strings.ToLower(getFieldOptions(field).GetIdType().String())
+	case isDecimalField(field):
+		return kindName(field.Desc) + " as decimal"
+	case field.Desc.IsMap():
+		return "map<" + kindName(field.Desc.MapKey()) + ", " + kindName(field.Desc.MapValue()) + ">"
+	case field.Desc.IsList():
+		return "repeated " + kindName(field.Desc)
+	}
+	return kindName(field.Desc)
+}
+
+// compareLockedFile 返回文件相对锁文件的不兼容变更，新增的消息和字段是兼容的
+func compareLockedFile(file *protogen.File, locked *lockedFile) []string {
+	messages := map[string]*protogen.Message{}
+	for _, message := range file.Messages {
+		messages[string(message.Desc.FullName())] = message
+	}
+	var problems []string
+	for _, lm := range locked.Messages {
+		message, ok := messages[lm.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: message removed", lm.Name))
+			continue
+		}
+		problems = append(problems, compareLockedMessage(message, lm)...)
+	}
+	return problems
+}
+
+// compareLockedMessage 按字段编号比较，编号变化、类型变化、未声明别名的键名变化和未保留编号的删除都不兼容
+// 键名改由其他编号的字段使用（新字段复用已删除字段的键名，或键名移到另一个字段）时，旧文档中的值会按新字段解码，同样不兼容
+func compareLockedMessage(message *protogen.Message, locked *lockedMessage) []string {
+	var problems []string
+	for _, lf := range locked.Fields {
+		where := fmt.Sprintf("%s.%s (%d)", locked.Name, lf.Name, lf.Number)
+		var field, renamed, reused *protogen.Field
+		for _, f := range message.Fields {
+			if int32(f.Desc.Number()) == lf.Number {
+				field = f
+			}
+			if getFieldName(f) == lf.Name {
+				renamed = f
+			}
+			if getBsonName(f) == lf.BsonName && int32(f.Desc.Number()) != lf.Number {
+				reused = f
+			}
+		}
+		// 编号变化已作为renumbered报告
+		if reused != nil && !(field == nil && reused == renamed) {
+			problems = append(problems, fmt.Sprintf("%s: bson name %q is now used by %s (%d)",
+				where, lf.BsonName, getFieldName(reused), reused.Desc.Number()))
+		}
+		if field == nil {
+			switch {
+			case renamed != nil:
+				problems = append(problems, fmt.Sprintf("%s: renumbered to %d", where, renamed.Desc.Number()))
+			case !message.Desc.ReservedRanges().Has(protoreflect.FieldNumber(lf.Number)):
+				problems = append(problems, fmt.Sprintf("%s: removed without reserving the field number", where))
+			}
+			continue
+		}
+		if typeName := lockTypeName(field); typeName != lf.Type {
+			problems = append(problems, fmt.Sprintf("%s: type changed from %s to %s", where, lf.Type, typeName))
+		}
+		bsonName := getBsonName(field)
+		if bsonName != lf.BsonName && !slices.Contains(getFieldOptions(field).GetPreviousNames(), lf.BsonName) {
+			problems = append(problems, fmt.Sprintf("%s: bson name changed from %q to %q without %q in previous_names",
+				where, lf.BsonName, bsonName, lf.BsonName))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"DB/orm/ormpb"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// lockTestField 测试消息中的string字段，bsonName为空时键名与字段名相同
+type lockTestField struct {
+	name     string
+	number   int32
+	bsonName string
+}
+
+// lockTestMessage 编译只包含一个消息的proto文件
+func lockTestMessage(t *testing.T, fields []lockTestField, reserved ...int32) *protogen.Message {
+	t.Helper()
+	message := &descriptorpb.DescriptorProto{Name: proto.String("Item")}
+	for _, f := range fields {
+		field := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(f.name),
+			Number:   proto.Int32(f.number),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			JsonName: proto.String(f.name),
+		}
+		if f.bsonName != "" {
+			field.Options = &descriptorpb.FieldOptions{}
+			proto.SetExtension(field.Options, ormpb.E_Field, &ormpb.FieldOptions{BsonName: f.bsonName})
+		}
+		message.Field = append(message.Field, field)
+	}
+	for _, number := range reserved {
+		message.ReservedRange = append(message.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+			Start: proto.Int32(number),
+			End:   proto.Int32(number + 1),
+		})
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("lock_test.proto"),
+		Package:     proto.String("locktest"),
+		Syntax:      proto.String("proto3"),
+		Options:     &descriptorpb.FileOptions{GoPackage: proto.String("example.com/locktest")},
+		MessageType: []*descriptorpb.DescriptorProto{message},
+	}
+	gen, err := protogen.Options{}.New(&pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{file.GetName()},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{file},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gen.Files[0].Messages[0]
+}
+
+func TestCompareLockedMessage(t *testing.T) {
+	published := &lockedMessage{Name: "locktest.Item", Fields: []*lockedField{
+		{Name: "name", Number: 1, Type: "string", BsonName: "name"},
+		{Name: "email", Number: 2, Type: "string", BsonName: "email"},
+		{Name: "phone", Number: 3, Type: "string", BsonName: "phone"},
+	}}
+	tests := []struct {
+		name     string
+		fields   []lockTestField
+		reserved []int32
+		want     []string // 报告中应包含的内容，为空表示兼容
+	}{
+		{
+			name:   "unchanged",
+			fields: []lockTestField{{"name", 1, ""}, {"email", 2, ""}, {"phone", 3, ""}},
+		},
+		{
+			name:   "new field",
+			fields: []lockTestField{{"name", 1, ""}, {"email", 2, ""}, {"phone", 3, ""}, {"address", 4, ""}},
+		},
+		{
+			name:     "removed and reserved",
+			fields:   []lockTestField{{"name", 1, ""}, {"email", 2, ""}},
+			reserved: []int32{3},
+		},
+		{
+			name:   "removed without reserving",
+			fields: []lockTestField{{"name", 1, ""}, {"email", 2, ""}},
+			want:   []string{"locktest.Item.phone (3): removed without reserving the field number"},
+		},
+		{
+			name:   "renumbered",
+			fields: []lockTestField{{"name", 1, ""}, {"email", 2, ""}, {"phone", 4, ""}},
+			want:   []string{"locktest.Item.phone (3): renumbered to 4"},
+		},
+		{
+			name:     "new field reuses the bson name of a removed field",
+			fields:   []lockTestField{{"name", 1, ""}, {"email", 2, ""}, {"mobile", 4, "phone"}},
+			reserved: []int32{3},
+			want:     []string{`locktest.Item.phone (3): bson name "phone" is now used by mobile (4)`},
+		},
+		{
+			name:   "bson name moves to another field",
+			fields: []lockTestField{{"name", 1, ""}, {"email", 2, "phone"}, {"phone", 3, "email"}},
+			want: []string{
+				`locktest.Item.email (2): bson name "email" is now used by phone (3)`,
+				`locktest.Item.phone (3): bson name "phone" is now used by email (2)`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := compareLockedMessage(lockTestMessage(t, tt.fields, tt.reserved...), published)
+			report := strings.Join(problems, "\n")
+			if len(tt.want) == 0 && len(problems) > 0 {
+				t.Fatalf("unexpected breaking changes:\n%s", report)
+			}
+			if len(tt.want) > 0 && len(problems) == 0 {
+				t.Fatal("breaking change not reported")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(report, want) {
+					t.Errorf("report does not contain %q:\n%s", want, report)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// internalName 返回生成代码内部调用的方法名
+// 线程安全模式下公开方法会加锁，内部调用改用小写的无锁实现，避免重入死锁
+func internalName(name string) string {
+	if !threadSafe {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// lockStatement 返回公开方法开头的加锁语句，非线程安全模式下为空
+func lockStatement(write bool) string {
+	if !threadSafe {
+		return ""
+	}
+	if write {
+		return "\tdefer x.lock()()"
+	}
+	return "\tdefer x.rlock()()"
+}
+
+// generateLock 在线程安全模式下输出加锁语句
+func generateLock(g *protogen.GeneratedFile, write bool) {
+	if stmt := lockStatement(write); stmt != "" {
+		g.P(stmt)
+	}
+}
+
+// generateLockedMethod 生成会被其他生成方法内部调用的公开方法
+// 默认模式下直接输出方法体；线程安全模式下公开方法加锁后调用同名小写的无锁实现
+func generateLockedMethod(g *protogen.GeneratedFile, structName, name, params, args, results string, write bool, body func()) {
+	signature := "(" + params + ")"
+	if results != "" {
+		signature += " " + results
+	}
+	if !threadSafe {
+		g.P("func (x *", structName, ") ", name, signature, " {")
+		body()
+		g.P("}")
+		g.P()
+		return
+	}
+
+	inner := internalName(name)
+	g.P("func (x *", structName, ") ", name, signature, " {")
+	g.P(lockStatement(write))
+	if results != "" {
+		g.P("\treturn x.", inner, "(", args, ")")
+	} else {
+		g.P("\tx.", inner, "(", args, ")")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// ", inner, " ", name, "的无锁实现，调用方需持有聚合根的锁")
+	g.P("func (x *", structName, ") ", inner, signature, " {")
+	body()
+	g.P("}")
+	g.P()
+}
+
+// generateAggregateMember 生成聚合成员接口，嵌套消息通过它找到根对象的锁并在持锁状态下通知父对象
+func generateAggregateMember(g *protogen.GeneratedFile) {
+	if !threadSafe {
+		return
+	}
+	g.P("// aggregateMember 生成类型之间使用的聚合接口，嵌套消息通过父对象链使用根对象的锁")
+	g.P("type aggregateMember interface {")
+	g.P("\trootLocker() *", syncPackage.Ident("RWMutex"))
+	g.P("\tnotifyFieldChanged(fieldIndex int)")
+	g.P("}")
+	g.P()
+}
+
+// generateLockHelpers 生成获取聚合根锁的辅助方法
+func generateLockHelpers(g *protogen.GeneratedFile, structName string) {
+	if !threadSafe {
+		return
+	}
+	g.P("// rootLocker 返回聚合根的读写锁，挂在父对象下的嵌套消息使用根对象的锁")
+	g.P("func (x *", structName, ") rootLocker() *", syncPackage.Ident("RWMutex"), " {")
+	g.P("\tif parent, ok := x.parentNotifier.(aggregateMember); ok {")
+	g.P("\t\treturn parent.rootLocker()")
+	g.P("\t}")
+	g.P("\treturn &x.mu")
+	g.P("}")
+	g.P()
+
+	g.P("// lock 加聚合根写锁，返回解锁函数")
+	g.P("func (x *", structName, ") lock() func() {")
+	g.P("\tif x == nil {")
+	g.P("\t\treturn func() {}")
+	g.P("\t}")
+	g.P("\tmu := x.rootLocker()")
+	g.P("\tmu.Lock()")
+	g.P("\treturn mu.Unlock")
+	g.P("}")
+	g.P()
+
+	g.P("// rlock 加聚合根读锁，返回解锁函数")
+	g.P("func (x *", structName, ") rlock() func() {")
+	g.P("\tif x == nil {")
+	g.P("\t\treturn func() {}")
+	g.P("\t}")
+	g.P("\tmu := x.rootLocker()")
+	g.P("\tmu.RLock()")
+	g.P("\treturn mu.RUnlock")
+	g.P("}")
+	g.P()
+}
+
+// generateCopyValue 生成把字段值复制到局部变量的代码
+// 线程安全模式下数组、字典和bytes在锁外仍可能被修改，返回给调用方前需要复制
+func generateCopyValue(g *protogen.GeneratedFile, field *protogen.Field, indent, dst, src string) {
+	fieldType := getGoType(field)
+	switch {
+	case field.Desc.IsMap():
+		g.P(indent, dst, " := make(", fieldType, ", len(", src, "))")
+		g.P(indent, "for key, value := range ", src, " {")
+		g.P(indent, "\t", dst, "[key] = value")
+		g.P(indent, "}")
+	case field.Desc.IsList() || fieldType == "[]byte":
+		g.P(indent, dst, " := make(", fieldType, ", len(", src, "))")
+		g.P(indent, "copy(", dst, ", ", src, ")")
+	default:
+		g.P(indent, dst, " := ", src)
+	}
+}
+
+// needsCopy 判断线程安全模式下字段值是否需要复制后再交给调用方
+func needsCopy(field *protogen.Field) bool {
+	return threadSafe && (isArrayOrMap(field) || getGoType(field) == "[]byte")
+}
@@ -12,6 +12,39 @@ import (
 
 var (
 	flags flag.FlagSet
+
+	// threadSafe 插件参数thread_safe=true时生成带读写锁的并发安全类型
+	threadSafe bool
+	// rollback 插件参数rollback=true时记录字段原值并生成Rollback方法
+	rollback bool
+	// audit 插件参数audit=true时记录字段原值并生成写入审计记录的保存路径
+	audit bool
+	// schemaLock 插件参数schema_lock=<文件>，与锁文件比较字段编号、类型和键名，有不兼容的变更时生成失败
+	schemaLock string
+	// updateSchemaLock 插件参数update_schema_lock=true时接受不兼容的变更并重写锁文件
+	updateSchemaLock bool
+)
+
+// 生成代码依赖的包
+const (
+	bsonPackage      = protogen.GoImportPath("go.mongodb.org/mongo-driver/bson")
+	contextPackage   = protogen.GoImportPath("context")
+	errorsPackage    = protogen.GoImportPath("errors")
+	fieldmaskPackage = protogen.GoImportPath("google.golang.org/protobuf/types/known/fieldmaskpb")
+	fmtPackage       = protogen.GoImportPath("fmt")
+	mapsPackage      = protogen.GoImportPath("maps")
+	mongoPackage     = protogen.GoImportPath("go.mongodb.org/mongo-driver/mongo")
+	optionsPackage   = protogen.GoImportPath("go.mongodb.org/mongo-driver/mongo/options")
+	primitivePackage = protogen.GoImportPath("go.mongodb.org/mongo-driver/bson/primitive")
+	ormPackage       = protogen.GoImportPath("DB/orm")
+	reflectPackage   = protogen.GoImportPath("reflect")
+	regexpPackage    = protogen.GoImportPath("regexp")
+	slicesPackage    = protogen.GoImportPath("slices")
+	strconvPackage   = protogen.GoImportPath("strconv")
+	stringsPackage   = protogen.GoImportPath("strings")
+	syncPackage      = protogen.GoImportPath("sync")
+	timePackage      = protogen.GoImportPath("time")
+	utf8Package      = protogen.GoImportPath("unicode/utf8")
 )
 
 func main() {
@@ -23,16 +56,37 @@ func main() {
 	}
 
 	var genFlags flag.FlagSet
+	genFlags.BoolVar(&threadSafe, "thread_safe", false, "generate types guarded by a per-aggregate RWMutex")
+	genFlags.BoolVar(&rollback, "rollback", false, "capture original field values and generate Rollback methods")
+	genFlags.BoolVar(&audit, "audit", false, "capture original field values and write audit entries on Save")
+	genFlags.StringVar(&schemaLock, "schema_lock", "", "lock file recording field numbers, types and bson names; breaking changes fail generation")
+	genFlags.BoolVar(&updateSchemaLock, "update_schema_lock", false, "accept breaking changes and rewrite the schema lock file")
 
 	protogen.Options{
 		ParamFunc: genFlags.Set,
 	}.Run(func(gen *protogen.Plugin) error {
 		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		for _, f := range gen.Files {
+			if !f.Generate {
+				continue
+			}
+			for _, message := range f.Messages {
+				if err := checkMessageOptions(message); err != nil {
+					return err
+				}
+			}
+		}
+		if schemaLock != "" {
+			if err := checkSchemaLock(gen); err != nil {
+				return err
+			}
+		}
 		for _, f := range gen.Files {
 			if !f.Generate {
 				continue
 			}
 			generateFile(gen, f)
+			generateRepositoryFile(gen, f)
 		}
 		return nil
 	})
@@ -45,7 +99,21 @@ func generateFile(gen *protogen.Plugin, file *protogen.File) {
 
 	filename := file.GeneratedFilenamePrefix + "_fields.pb.go"
 	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+	generateHeader(gen, g, file)
+
+	// 生成ParentNotifier接口
+	generateParentNotifier(g)
+	generateAggregateMember(g)
+	generateFieldListenerType(g)
+
+	// 生成结构体和方法
+	for _, message := range file.Messages {
+		generateMessage(g, message)
+	}
+}
 
+// generateHeader 生成文件头和package声明，导入由protogen根据使用情况自动生成
+func generateHeader(gen *protogen.Plugin, g *protogen.GeneratedFile, file *protogen.File) {
 	g.P("// Code generated by protoc-gen-mongo. DO NOT EDIT.")
 	g.P("// versions:")
 	g.P("// \tprotoc-gen-mongo v1.0.0")
@@ -58,23 +126,9 @@ func generateFile(gen *protogen.Plugin, file *protogen.File) {
 	g.P()
 	g.P("package ", file.GoPackageName)
 	g.P()
-
-	// 生成导入
-	generateImports(g)
-
-	// 生成结构体和方法
-	for _, message := range file.Messages {
-		generateMessage(g, message)
-	}
 }
 
-func generateImports(g *protogen.GeneratedFile) {
-	g.P("import (")
-	g.P("\t\"reflect\"")
-	g.P(")")
-	g.P()
-
-	// 生成ParentNotifier接口
+func generateParentNotifier(g *protogen.GeneratedFile) {
 	g.P("// ParentNotifier 定义父对象通知接口，避免使用反射")
 	g.P("type ParentNotifier interface {")
 	g.P("\tNotifyFieldChanged(fieldIndex int)")
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"google.golang.org/protobuf/compiler/protogen"
@@ -10,12 +11,14 @@ import (
 
 func generateMessage(g *protogen.GeneratedFile, message *protogen.Message) {
 	structName := message.GoIdent.GoName
+	importCustomTypes(g, message)
 
 	// 生成私有字段结构体
 	generatePrivateStruct(g, message, structName)
 
 	// 生成脏标记结构体
 	generateDirtyStruct(g, message, structName)
+	generateOriginalStruct(g, message, structName)
 
 	// 生成构造函数
 	generateConstructor(g, message, structName)
@@ -29,8 +32,37 @@ func generateMessage(g *protogen.GeneratedFile, message *protogen.Message) {
 	// 生成Getter/Setter方法
 	generateAccessors(g, message, structName)
 
+	// 生成字段变更订阅方法
+	generateListenerMethods(g, message, structName)
+
 	// 生成脏标记管理方法
 	generateDirtyMethods(g, message, structName)
+
+	// 生成脏状态快照方法
+	generateSnapshotMethods(g, message, structName)
+
+	// 生成撤销修改方法
+	generateRollbackMethods(g, message, structName)
+	generateAuditMethods(g, message, structName)
+
+	// 生成BSON编解码和增量更新方法
+	generateBSONMethods(g, message, structName)
+
+	// 生成实例比较方法
+	generateDiff(g, message, structName)
+
+	// 生成字段掩码方法
+	generateFieldMaskMethods(g, message, structName)
+
+	// 生成部分加载状态方法
+	generatePartialMethods(g, message, structName)
+
+	// 生成查询用的字段路径
+	generateFieldPaths(g, message, structName)
+	generateValidateMethods(g, message, structName)
+
+	// 生成$jsonSchema校验器
+	generateJSONSchema(g, message, structName)
 }
 
 func generatePrivateStruct(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
@@ -51,6 +83,14 @@ func generatePrivateStruct(g *protogen.GeneratedFile, message *protogen.Message,
 	g.P("\t// 父对象通知回调，用于嵌套脏标记同步")
 	g.P("\tparentNotifier ParentNotifier")
 	g.P("\tparentFieldIndex int // 在父对象中的字段索引")
+	g.P("\t// 字段变更监听器")
+	g.P("\tlisteners []*fieldListener")
+	generatePartialFields(g, message)
+	generateSchemaVersionFields(g, message)
+	if threadSafe {
+		g.P("\t// 作为聚合根时使用的读写锁，挂在父对象下时改用根对象的锁")
+		g.P("\tmu ", syncPackage.Ident("RWMutex"))
+	}
 	g.P("}")
 	g.P()
 }
@@ -69,6 +109,12 @@ func generateDirtyStruct(g *protogen.GeneratedFile, message *protogen.Message, s
 	} else {
 		g.P("\tFieldsBitmap [", bitmapSize, "]uint64")
 	}
+	g.P("\t// 整体替换位图，标记字典和嵌套消息字段被整体赋值，更新时不再按元素或子路径展开")
+	if bitmapSize == 1 {
+		g.P("\tReplacedBitmap uint64")
+	} else {
+		g.P("\tReplacedBitmap [", bitmapSize, "]uint64")
+	}
 
 	// 为数组和字典字段生成额外的跟踪
 	for i, field := range message.Fields {
@@ -80,6 +126,10 @@ func generateDirtyStruct(g *protogen.GeneratedFile, message *protogen.Message, s
 		g.P("\t// ", field.GoName, " field index: ", i)
 	}
 
+	generateOriginalDirtyFields(g, structName, bitmapSize)
+	generateIncrementDirtyFields(g, message)
+	generateSchemaUpgradedField(g, message)
+
 	g.P("\tTotalChanges int // 总变更数量")
 	g.P("\tTotalFields  int // 总字段数量")
 	g.P("}")
@@ -118,14 +168,15 @@ func generateConstructor(g *protogen.GeneratedFile, message *protogen.Message, s
 
 	// 生成SetParentNotifier方法
 	g.P("// SetParentNotifier 设置父对象通知器")
-	g.P("func (x *", structName, ") SetParentNotifier(notifier ParentNotifier, fieldIndex int) {")
-	g.P("\tif x == nil {")
-	g.P("\t\treturn")
-	g.P("\t}")
-	g.P("\tx.parentNotifier = notifier")
-	g.P("\tx.parentFieldIndex = fieldIndex")
-	g.P("}")
-	g.P()
+	generateLockedMethod(g, structName, "SetParentNotifier", "notifier ParentNotifier, fieldIndex int", "notifier, fieldIndex", "", true, func() {
+		g.P("\tif x == nil {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		g.P("\tx.parentNotifier = notifier")
+		g.P("\tx.parentFieldIndex = fieldIndex")
+	})
+
+	generateLockHelpers(g, structName)
 }
 
 func generateAccessors(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
@@ -141,16 +192,27 @@ func generateAccessors(g *protogen.GeneratedFile, message *protogen.Message, str
 		g.P("\tif x == nil {")
 		g.P("\t\treturn ", getZeroValue(field))
 		g.P("\t}")
+		generateLock(g, false)
 
 		// 如果是message类型，设置父对象通知器
-		if field.Desc.Kind() == protoreflect.MessageKind {
+		// 线程安全模式下通知器只在赋值和加载时设置，读锁下不能写子对象
+		if isMessageField(field) && !threadSafe {
 			g.P("\tif x.", fieldName, " != nil {")
 			constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
 			g.P("\t\tx.", fieldName, ".SetParentNotifier(x, ", constName, ")")
 			g.P("\t}")
 		}
 
-		g.P("\treturn x.", fieldName)
+		if needsCopy(field) {
+			// 返回副本，避免调用方在锁外读取时与修改并发
+			g.P("\tif x.", fieldName, " == nil {")
+			g.P("\t\treturn nil")
+			g.P("\t}")
+			generateCopyValue(g, field, "\t", "v", "x."+fieldName)
+			g.P("\treturn v")
+		} else {
+			g.P("\treturn x.", fieldName)
+		}
 		g.P("}")
 		g.P()
 
@@ -160,30 +222,51 @@ func generateAccessors(g *protogen.GeneratedFile, message *protogen.Message, str
 		g.P("\tif x == nil {")
 		g.P("\t\treturn")
 		g.P("\t}")
-		g.P("\tx.EnsureDirty()")
-
-		// 检查值是否真的改变了
-		g.P("\tif !reflect.DeepEqual(x.", fieldName, ", v) {")
+		generateChangeEventSetup(g)
+		generateLock(g, true)
+		g.P("\tx.", internalName("EnsureDirty"), "()")
+
+		// 检查值是否真的改变了，未加载的字段无法比较，总是视为改变
+		if isDecimalField(field) {
+			// 按数值比较，1.50与1.5视为未改变
+			g.P("\tif !x.isFieldLoaded(", fieldIndex, ") || !x.", fieldName, ".Equal(v) {")
+		} else {
+			g.P("\tif !x.isFieldLoaded(", fieldIndex, ") || !", reflectPackage.Ident("DeepEqual"), "(x.", fieldName, ", v) {")
+		}
+		g.P("\t\toldValue := x.", fieldName)
+		generateCaptureOriginal(g, "\t\t", fmt.Sprintf("%s%sFieldIndex", structName, field.GoName))
 		g.P("\t\tif !x.isFieldDirty(", fieldIndex, ") {")
 		g.P("\t\t\tx.Dirty.TotalChanges++")
 		g.P("\t\t}")
 		g.P("\t\tx.setFieldDirty(", fieldIndex, ")")
+		if field.Desc.IsMap() || isMessageField(field) {
+			g.P("\t\tx.setFieldReplaced(", fieldIndex, ")")
+		}
+		if isDecimalField(field) {
+			g.P("\t\tx.Dirty.", incrementFieldName(field), " = nil")
+		}
 		g.P("\t\tx.", fieldName, " = v")
+		g.P("\t\tx.setFieldLoaded(", fieldIndex, ")")
 
 		// 如果是message类型，设置父对象通知器
-		if field.Desc.Kind() == protoreflect.MessageKind {
+		if isMessageField(field) {
 			g.P("\t\tif x.", fieldName, " != nil {")
 			constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
-			g.P("\t\t\tx.", fieldName, ".SetParentNotifier(x, ", constName, ")")
+			g.P("\t\t\tx.", fieldName, ".", internalName("SetParentNotifier"), "(x, ", constName, ")")
 			g.P("\t\t}")
 		}
 
 		// 通知父对象脏标记更新
 		g.P("\t\tx.notifyParentDirty()")
+		generateChangeEvent(g, field, "\t\t", fmt.Sprintf("%s%sFieldIndex", structName, field.GoName), "oldValue", "v")
 		g.P("\t}")
 		g.P("}")
 		g.P()
 
+		if isDecimalField(field) {
+			generateIncMethod(g, structName, field, fieldIndex)
+		}
+
 		// 如果是数组或字典，生成额外的操作方法
 		if isArrayOrMap(field) {
 			generateCollectionMethods(g, message, field, structName, fieldName, publicName, fieldType)
@@ -202,6 +285,17 @@ func generateCollectionMethods(g *protogen.GeneratedFile, message *protogen.Mess
 			break
 		}
 	}
+	constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+
+	// markDirty 输出标记字段脏并通知父对象和监听器的代码
+	markDirty := func(indent string) {
+		g.P(indent, "if !x.isFieldDirty(", fieldIndex, ") {")
+		g.P(indent, "\tx.Dirty.TotalChanges++")
+		g.P(indent, "\tx.setFieldDirty(", fieldIndex, ")")
+		g.P(indent, "}")
+		g.P(indent, "x.notifyParentDirty()")
+		generateChangeEvent(g, field, indent, constName, "oldValue", "x."+fieldName)
+	}
 
 	if field.Desc.IsList() {
 		// 数组操作方法
@@ -212,31 +306,56 @@ func generateCollectionMethods(g *protogen.GeneratedFile, message *protogen.Mess
 		g.P("\tif x == nil {")
 		g.P("\t\treturn")
 		g.P("\t}")
-		g.P("\tx.EnsureDirty()")
+		generateChangeEventSetup(g)
+		generateLock(g, true)
+		g.P("\tx.", internalName("EnsureDirty"), "()")
+		generateOldValueCapture(g, field, fieldName)
+		generateCaptureOriginal(g, "\t", constName)
 		g.P("\tx.", fieldName, " = append(x.", fieldName, ", v)")
 		g.P("\tindex := len(x.", fieldName, ") - 1")
 		g.P("\tx.Dirty.", publicFieldName, "Elements[index] = true")
-		g.P("\tif !x.isFieldDirty(", fieldIndex, ") {")
-		g.P("\t\tx.Dirty.TotalChanges++")
-		g.P("\t\tx.setFieldDirty(", fieldIndex, ")")
-		g.P("\t}")
+		markDirty("\t")
 		g.P("}")
 		g.P()
 
 		g.P("// Set", publicName, "Element 设置", fieldName, "指定位置的元素")
 		g.P("func (x *", structName, ") Set", publicName, "Element(index int, v ", elementType, ") {")
-		g.P("\tif x == nil || index < 0 || index >= len(x.", fieldName, ") {")
+		g.P("\tif x == nil {")
 		g.P("\t\treturn")
 		g.P("\t}")
-		g.P("\tx.EnsureDirty()")
-		g.P("\tif !reflect.DeepEqual(x.", fieldName, "[index], v) {")
-		g.P("\t\tx.", fieldName, "[index] = v")
-		g.P("\t\tx.Dirty.", publicFieldName, "Elements[index] = true")
-		g.P("\t\tif !x.isFieldDirty(", fieldIndex, ") {")
-		g.P("\t\t\tx.Dirty.TotalChanges++")
-		g.P("\t\t\tx.setFieldDirty(", fieldIndex, ")")
-		g.P("\t\t}")
+		generateChangeEventSetup(g)
+		generateLock(g, true)
+		g.P("\tif index < 0 || index >= len(x.", fieldName, ") {")
+		g.P("\t\treturn")
 		g.P("\t}")
+		g.P("\tx.", internalName("EnsureDirty"), "()")
+		g.P("\tif ", reflectPackage.Ident("DeepEqual"), "(x.", fieldName, "[index], v) {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		generateOldValueCapture(g, field, fieldName)
+		generateCaptureOriginal(g, "\t", constName)
+		g.P("\tx.", fieldName, "[index] = v")
+		g.P("\tx.Dirty.", publicFieldName, "Elements[index] = true")
+		markDirty("\t")
+		g.P("}")
+		g.P()
+
+		g.P("// Remove", publicName, "Element 删除", fieldName, "指定位置的元素，后面的元素前移")
+		g.P("func (x *", structName, ") Remove", publicName, "Element(index int) {")
+		g.P("\tif x == nil {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		generateChangeEventSetup(g)
+		generateLock(g, true)
+		g.P("\tif index < 0 || index >= len(x.", fieldName, ") {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		g.P("\tx.", internalName("EnsureDirty"), "()")
+		generateOldValueCapture(g, field, fieldName)
+		generateCaptureOriginal(g, "\t", constName)
+		g.P("\tx.", fieldName, " = append(x.", fieldName, "[:index], x.", fieldName, "[index+1:]...)")
+		g.P("\tx.Dirty.", publicFieldName, "Elements[index] = true")
+		markDirty("\t")
 		g.P("}")
 		g.P()
 
@@ -249,27 +368,87 @@ func generateCollectionMethods(g *protogen.GeneratedFile, message *protogen.Mess
 		g.P("\tif x == nil {")
 		g.P("\t\treturn")
 		g.P("\t}")
-		g.P("\tx.EnsureDirty()")
+		generateChangeEventSetup(g)
+		generateLock(g, true)
+		g.P("\tx.", internalName("EnsureDirty"), "()")
+		g.P("\tif oldValue, exists := x.", fieldName, "[key]; exists && ", reflectPackage.Ident("DeepEqual"), "(oldValue, value) {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		generateOldValueCapture(g, field, fieldName)
+		generateCaptureOriginal(g, "\t", constName)
 		g.P("\tif x.", fieldName, " == nil {")
 		g.P("\t\tx.", fieldName, " = make(", fieldType, ")")
 		g.P("\t}")
-		g.P("\toldValue, exists := x.", fieldName, "[key]")
-		g.P("\tif !exists || !reflect.DeepEqual(oldValue, value) {")
-		g.P("\t\tx.", fieldName, "[key] = value")
-		g.P("\t\tx.Dirty.", publicFieldName, "Elements[key] = true")
-		g.P("\t\tif !x.isFieldDirty(", fieldIndex, ") {")
-		g.P("\t\t\tx.Dirty.TotalChanges++")
-		g.P("\t\t\tx.setFieldDirty(", fieldIndex, ")")
-		g.P("\t\t}")
+		g.P("\tx.", fieldName, "[key] = value")
+		g.P("\tx.Dirty.", publicFieldName, "Elements[key] = true")
+		generateUnsafeKeyReplace(g, field, constName)
+		markDirty("\t")
+		g.P("}")
+		g.P()
+
+		g.P("// Remove", publicName, "Value 删除", fieldName, "中指定的键，保存时生成$unset")
+		g.P("func (x *", structName, ") Remove", publicName, "Value(key ", keyType, ") {")
+		g.P("\tif x == nil {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		generateChangeEventSetup(g)
+		generateLock(g, true)
+		g.P("\tif _, exists := x.", fieldName, "[key]; !exists {")
+		g.P("\t\treturn")
 		g.P("\t}")
+		g.P("\tx.", internalName("EnsureDirty"), "()")
+		generateOldValueCapture(g, field, fieldName)
+		generateCaptureOriginal(g, "\t", constName)
+		g.P("\tdelete(x.", fieldName, ", key)")
+		g.P("\tx.Dirty.", publicFieldName, "Elements[key] = true")
+		generateUnsafeKeyReplace(g, field, constName)
+		markDirty("\t")
 		g.P("}")
 		g.P()
 	}
 }
 
+// generateUnsafeKeyReplace 字符串键不能作为点分路径的一段时把字典标记为整体替换，保存时写入整个字典
+func generateUnsafeKeyReplace(g *protogen.GeneratedFile, field *protogen.Field, constName string) {
+	if keyType, _ := getMapTypes(field); keyType != "string" {
+		return
+	}
+	g.P("\tif !", ormPackage.Ident("IsSafeMapKey"), "(key) {")
+	g.P("\t\tx.setFieldReplaced(", constName, ")")
+	g.P("\t}")
+}
+
 // 辅助函数
 
+// customTypeIdent 返回声明了id_type或decimal的字段在生成结构体中的类型，其余字段按proto类型映射
+func customTypeIdent(field *protogen.Field) (protogen.GoIdent, bool) {
+	if isDecimalField(field) {
+		return ormPackage.Ident("Decimal"), true
+	}
+	return idTypeIdent(field)
+}
+
+// hasCustomType 判断字段在生成结构体中是否使用customTypeIdent返回的类型
+func hasCustomType(field *protogen.Field) bool {
+	_, ok := customTypeIdent(field)
+	return ok
+}
+
+// importCustomTypes 为消息中使用自定义类型的字段登记导入，生成代码中的类型名由getGoType按字符串拼出
+func importCustomTypes(g *protogen.GeneratedFile, message *protogen.Message) {
+	for _, field := range message.Fields {
+		if ident, ok := customTypeIdent(field); ok {
+			g.QualifiedGoIdent(ident)
+		}
+	}
+}
+
 func getGoType(field *protogen.Field) string {
+	if ident, ok := customTypeIdent(field); ok {
+		// 包名与importCustomTypes登记的导入一致
+		return path.Base(string(ident.GoImportPath)) + "." + ident.GoName
+	}
+
 	// 先检查是否是数组或映射
 	if field.Desc.IsList() {
 		elementType := getElementType(field)
@@ -391,6 +570,13 @@ func getGoTypeForMapField(field *protogen.Field) string {
 }
 
 func getZeroValue(field *protogen.Field) string {
+	if isArrayOrMap(field) {
+		return "nil"
+	}
+	if hasCustomType(field) {
+		return getGoType(field) + "{}"
+	}
+
 	switch field.Desc.Kind() {
 	case protoreflect.StringKind:
 		return "\"\""
@@ -418,6 +604,11 @@ func isArrayOrMap(field *protogen.Field) bool {
 	return field.Desc.IsList() || field.Desc.IsMap()
 }
 
+// isMessageField 判断是否为单个嵌套消息字段（不含repeated和map）
+func isMessageField(field *protogen.Field) bool {
+	return !isArrayOrMap(field) && field.Desc.Kind() == protoreflect.MessageKind
+}
+
 // generateDirtyInitialization 生成dirty初始化代码
 func generateDirtyInitialization(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
 	g.P("\tif x.Dirty == nil {")
@@ -437,10 +628,9 @@ func generateDirtyInitialization(g *protogen.GeneratedFile, message *protogen.Me
 // generateEnsureDirtyMethod 生成ensureDirty私有方法
 func generateEnsureDirtyMethod(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
 	g.P("// EnsureDirty 确保dirty结构体已初始化")
-	g.P("func (x *", structName, ") EnsureDirty() {")
-	generateDirtyInitialization(g, message, structName)
-	g.P("}")
-	g.P()
+	generateLockedMethod(g, structName, "EnsureDirty", "", "", "", true, func() {
+		generateDirtyInitialization(g, message, structName)
+	})
 }
 
 // generateNotifyParentDirtyMethod 生成notifyParentDirty私有方法
@@ -450,6 +640,13 @@ func generateNotifyParentDirtyMethod(g *protogen.GeneratedFile, message *protoge
 	g.P("\tif x == nil || x.parentNotifier == nil {")
 	g.P("\t\treturn")
 	g.P("\t}")
+	if threadSafe {
+		g.P("\t// 生成类型的父对象与本对象共用根对象的锁，调用无锁实现避免重入")
+		g.P("\tif parent, ok := x.parentNotifier.(aggregateMember); ok {")
+		g.P("\t\tparent.notifyFieldChanged(x.parentFieldIndex)")
+		g.P("\t\treturn")
+		g.P("\t}")
+	}
 	g.P("\t// 直接调用父对象的NotifyFieldChanged方法，避免反射")
 	g.P("\tx.parentNotifier.NotifyFieldChanged(x.parentFieldIndex)")
 	g.P("}")
@@ -457,51 +654,62 @@ func generateNotifyParentDirtyMethod(g *protogen.GeneratedFile, message *protoge
 
 	// 生成NotifyFieldChanged方法实现
 	g.P("// NotifyFieldChanged 实现ParentNotifier接口")
-	g.P("func (x *", structName, ") NotifyFieldChanged(fieldIndex int) {")
-	g.P("\tif x == nil {")
-	g.P("\t\treturn")
-	g.P("\t}")
-	g.P("\tx.EnsureDirty()")
-	g.P("\tif !x.isFieldDirty(fieldIndex) {")
-	g.P("\t\tx.Dirty.TotalChanges++")
-	g.P("\t\tx.setFieldDirty(fieldIndex)")
-	g.P("\t}")
-	g.P("\tx.notifyParentDirty() // 递归通知父对象")
-	g.P("}")
-	g.P()
+	generateLockedMethod(g, structName, "NotifyFieldChanged", "fieldIndex int", "fieldIndex", "", true, func() {
+		g.P("\tif x == nil {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		g.P("\tx.", internalName("EnsureDirty"), "()")
+		g.P("\tif !x.isFieldDirty(fieldIndex) {")
+		g.P("\t\tx.Dirty.TotalChanges++")
+		g.P("\t\tx.setFieldDirty(fieldIndex)")
+		g.P("\t}")
+		g.P("\tx.notifyParentDirty() // 递归通知父对象")
+	})
 
 	// 生成位图操作辅助方法
-	g.P("// isFieldDirty 检查指定字段是否脏")
-	g.P("func (x *", structName, ") isFieldDirty(fieldIndex int) bool {")
+	fieldCount := len(message.Fields)
+	bitmapSize := (fieldCount + 63) / 64
+
+	generateBitmapTest(g, structName, "isFieldDirty", "FieldsBitmap", "检查指定字段是否脏", bitmapSize)
+	generateBitmapSet(g, structName, "setFieldDirty", "FieldsBitmap", "设置指定字段为脏", bitmapSize)
+	generateBitmapTest(g, structName, "isFieldReplaced", "ReplacedBitmap", "检查指定字段是否被整体替换", bitmapSize)
+	generateBitmapSet(g, structName, "setFieldReplaced", "ReplacedBitmap", "标记指定字段被整体替换", bitmapSize)
+	generateUpgradedBitmapMethods(g, message, structName)
+}
+
+// generateBitmapTest 生成检查位图中指定位的方法
+func generateBitmapTest(g *protogen.GeneratedFile, structName, methodName, bitmapName, comment string, bitmapSize int) {
+	g.P("// ", methodName, " ", comment)
+	g.P("func (x *", structName, ") ", methodName, "(fieldIndex int) bool {")
 	g.P("\tif x == nil || x.Dirty == nil || fieldIndex < 0 {")
 	g.P("\t\treturn false")
 	g.P("\t}")
 
-	fieldCount := len(message.Fields)
-	bitmapSize := (fieldCount + 63) / 64
-
 	if bitmapSize == 1 {
-		g.P("\treturn (x.Dirty.FieldsBitmap & (1 << uint(fieldIndex))) != 0")
+		g.P("\treturn (x.Dirty.", bitmapName, " & (1 << uint(fieldIndex))) != 0")
 	} else {
 		g.P("\tbitmapIndex := fieldIndex / 64")
 		g.P("\tbitIndex := fieldIndex % 64")
-		g.P("\treturn (x.Dirty.FieldsBitmap[bitmapIndex] & (1 << uint(bitIndex))) != 0")
+		g.P("\treturn (x.Dirty.", bitmapName, "[bitmapIndex] & (1 << uint(bitIndex))) != 0")
 	}
 	g.P("}")
 	g.P()
+}
 
-	g.P("// setFieldDirty 设置指定字段为脏")
-	g.P("func (x *", structName, ") setFieldDirty(fieldIndex int) {")
+// generateBitmapSet 生成设置位图中指定位的方法
+func generateBitmapSet(g *protogen.GeneratedFile, structName, methodName, bitmapName, comment string, bitmapSize int) {
+	g.P("// ", methodName, " ", comment)
+	g.P("func (x *", structName, ") ", methodName, "(fieldIndex int) {")
 	g.P("\tif x == nil || x.Dirty == nil || fieldIndex < 0 {")
 	g.P("\t\treturn")
 	g.P("\t}")
 
 	if bitmapSize == 1 {
-		g.P("\tx.Dirty.FieldsBitmap |= (1 << uint(fieldIndex))")
+		g.P("\tx.Dirty.", bitmapName, " |= (1 << uint(fieldIndex))")
 	} else {
 		g.P("\tbitmapIndex := fieldIndex / 64")
 		g.P("\tbitIndex := fieldIndex % 64")
-		g.P("\tx.Dirty.FieldsBitmap[bitmapIndex] |= (1 << uint(bitIndex))")
+		g.P("\tx.Dirty.", bitmapName, "[bitmapIndex] |= (1 << uint(bitIndex))")
 	}
 	g.P("}")
 	g.P()
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// hasMigrations 判断消息或经由单值消息字段嵌套的消息是否声明了previous_names
+func hasMigrations(message *protogen.Message) bool {
+	visited := map[*protogen.Message]bool{}
+	var check func(m *protogen.Message) bool
+	check = func(m *protogen.Message) bool {
+		if visited[m] {
+			return false
+		}
+		visited[m] = true
+		for _, field := range m.Fields {
+			if len(getFieldOptions(field).GetPreviousNames()) > 0 {
+				return true
+			}
+			if isMessageField(field) && check(field.Message) {
+				return true
+			}
+		}
+		return false
+	}
+	return check(message)
+}
+
+// hasPreviousNames 判断消息自身是否有字段声明了previous_names
+func hasPreviousNames(message *protogen.Message) bool {
+	for _, field := range message.Fields {
+		if len(getFieldOptions(field).GetPreviousNames()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// generateLegacyCase 生成按旧键名解码字段的case，文档中同时存在新键名时以新键名为准
+func generateLegacyCase(g *protogen.GeneratedFile, structName string, field *protogen.Field) {
+	names := getFieldOptions(field).GetPreviousNames()
+	if len(names) == 0 {
+		return
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	g.P("\t\tcase ", strings.Join(quoted, ", "), ":")
+	g.P("\t\t\tif _, err := ", bsonPackage.Ident("Raw"), "(data).LookupErr(\"", getBsonName(field), "\"); err == nil {")
+	g.P("\t\t\t\tcontinue")
+	g.P("\t\t\t}")
+	generateFieldDecode(g, field)
+	g.P("\t\t\tmigrated = append(migrated, ", structName, field.GoName, "FieldIndex)")
+}
+
+// generateMigratedDirty 生成解码结束后的标脏代码
+// 从旧键名读取的字段整体标脏，下次保存时写入新键名并删除旧键名；嵌套消息有迁移时父字段随之标脏
+func generateMigratedDirty(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	if hasPreviousNames(message) {
+		g.P("\tfor _, index := range migrated {")
+		g.P("\t\tx.setFieldReplaced(index)")
+		g.P("\t\tx.", internalName("NotifyFieldChanged"), "(index)")
+		g.P("\t}")
+	}
+	for _, field := range message.Fields {
+		if !isMessageField(field) || !hasMigrations(field.Message) {
+			continue
+		}
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		g.P("\tif x.", fieldName, " != nil && x.", fieldName, ".Dirty != nil && x.", fieldName, ".Dirty.TotalChanges > 0 {")
+		g.P("\t\tx.", internalName("NotifyFieldChanged"), "(", fmt.Sprintf("%s%sFieldIndex", structName, field.GoName), ")")
+		g.P("\t}")
+	}
+}
+
+// generateVersionedUnmarshal 为声明了schema_version的消息生成UnmarshalBSON和UnmarshalProjectedBSON
+// 完整文档在解码前升级到当前版本；投影和聚合的结果可能缺少字段，不升级
+func generateVersionedUnmarshal(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	g.P("// UnmarshalBSON 实现bson.Unmarshaler接口，加载后的对象没有脏标记")
+	g.P("// data须为完整文档，缺少_v时视为版本1并依次执行注册的升级函数")
+	generateLockedMethod(g, structName, "UnmarshalBSON", "data []byte", "data", "error", true, func() {
+		g.P("\treturn x.decodeDocument(data, false)")
+	})
+
+	g.P("// UnmarshalProjectedBSON 解码投影查询或聚合的结果，文档可能缺少字段，不执行结构升级")
+	g.P("// _v缺失或不是当前版本时保存不写入_v，未升级的文档仍由完整加载或Migrate升级")
+	g.P("func (x *", structName, ") UnmarshalProjectedBSON(data []byte) error {")
+	generateLock(g, true)
+	g.P("\treturn x.decodeDocument(data, true)")
+	g.P("}")
+	g.P()
+
+	g.P("// decodeDocument UnmarshalBSON和UnmarshalProjectedBSON的无锁实现")
+	g.P("func (x *", structName, ") decodeDocument(data []byte, projected bool) error {")
+	g.P("\toriginal := ", bsonPackage.Ident("Raw"), "(data)")
+	g.P("\tupgraded := false")
+	g.P("\tx.staleSchema = projected && !", ormPackage.Ident("HasSchemaVersion"), "(data, ", structName, "SchemaVersion)")
+	g.P("\tif !projected {")
+	g.P("\t\tvar err error")
+	g.P("\t\tdata, upgraded, err = ", ormPackage.Ident("UpgradeDocument"), "(", strconv.Quote(string(message.Desc.FullName())), ", ", structName, "SchemaVersion, data)")
+	g.P("\t\tif err != nil {")
+	g.P("\t\t\treturn err")
+	g.P("\t\t}")
+	g.P("\t}")
+	generateUnmarshalBody(g, message, structName)
+	g.P("}")
+	g.P()
+}
+
+// generateSchemaUpgradedField 在脏标记结构体中记录加载时执行过升级
+func generateSchemaUpgradedField(g *protogen.GeneratedFile, message *protogen.Message) {
+	if getMessageOptions(message).GetSchemaVersion() == 0 {
+		return
+	}
+	g.P("\tSchemaUpgraded bool // 加载时执行过升级，没有字段改变时保存也写入_v")
+	if !trackUpgraded(message) {
+		return
+	}
+	g.P("\t// 升级改变了值的字段位图，升级后的值没有原值可恢复，Rollback后仍保持标脏")
+	if bitmapSize := (len(message.Fields) + 63) / 64; bitmapSize == 1 {
+		g.P("\tUpgradedBitmap uint64")
+	} else {
+		g.P("\tUpgradedBitmap [", bitmapSize, "]uint64")
+	}
+}
+
+// trackUpgraded rollback模式下声明了schema_version的消息需要记录升级改变了哪些字段
+func trackUpgraded(message *protogen.Message) bool {
+	return rollback && getMessageOptions(message).GetSchemaVersion() > 0
+}
+
+// generateUpgradedBitmapMethods 生成升级字段位图的辅助方法
+func generateUpgradedBitmapMethods(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	if !trackUpgraded(message) {
+		return
+	}
+	bitmapSize := (len(message.Fields) + 63) / 64
+	generateBitmapTest(g, structName, "isFieldUpgraded", "UpgradedBitmap", "检查指定字段是否在加载时被升级改变", bitmapSize)
+	generateBitmapSet(g, structName, "setFieldUpgraded", "UpgradedBitmap", "标记指定字段在加载时被升级改变", bitmapSize)
+}
+
+// generateUpgradedReset 生成ResetDirty中清除升级记录的代码
+func generateUpgradedReset(g *protogen.GeneratedFile, message *protogen.Message) {
+	if getMessageOptions(message).GetSchemaVersion() == 0 {
+		return
+	}
+	g.P("\tx.Dirty.SchemaUpgraded = false")
+	if !trackUpgraded(message) {
+		return
+	}
+	if bitmapSize := (len(message.Fields) + 63) / 64; bitmapSize == 1 {
+		g.P("\tx.Dirty.UpgradedBitmap = 0")
+	} else {
+		g.P("\tx.Dirty.UpgradedBitmap = [", bitmapSize, "]uint64{}")
+	}
+}
+
+// generateUpgradedMerge 生成RestoreDirty中合并升级记录的代码
+func generateUpgradedMerge(g *protogen.GeneratedFile, message *protogen.Message) {
+	if getMessageOptions(message).GetSchemaVersion() == 0 {
+		return
+	}
+	g.P("\tx.Dirty.SchemaUpgraded = x.Dirty.SchemaUpgraded || s.dirty.SchemaUpgraded")
+	if !trackUpgraded(message) {
+		return
+	}
+	if bitmapSize := (len(message.Fields) + 63) / 64; bitmapSize == 1 {
+		g.P("\tx.Dirty.UpgradedBitmap |= s.dirty.UpgradedBitmap")
+	} else {
+		g.P("\tfor i := range x.Dirty.UpgradedBitmap {")
+		g.P("\t\tx.Dirty.UpgradedBitmap[i] |= s.dirty.UpgradedBitmap[i]")
+		g.P("\t}")
+	}
+}
+
+// generateUpgradedRollback 生成Rollback中保留升级标脏的代码，before为true时生成ResetDirty之前的部分
+// 升级后的值留在内存中且没有原值可恢复，清除标脏后下次保存会写入_v而不写升级后的字段
+func generateUpgradedRollback(g *protogen.GeneratedFile, message *protogen.Message, before bool) {
+	if !trackUpgraded(message) {
+		return
+	}
+	if before {
+		g.P("\tupgraded, upgradedFields := x.Dirty.SchemaUpgraded, x.Dirty.UpgradedBitmap")
+		return
+	}
+	g.P("\t// 加载时升级改变的字段仍需写回")
+	g.P("\tx.Dirty.SchemaUpgraded = upgraded")
+	g.P("\tx.Dirty.UpgradedBitmap = upgradedFields")
+	g.P("\tfor i := 0; i < ", len(message.Fields), "; i++ {")
+	g.P("\t\tif x.isFieldUpgraded(i) {")
+	g.P("\t\t\tx.setFieldDirty(i)")
+	g.P("\t\t\tx.setFieldReplaced(i)")
+	g.P("\t\t}")
+	g.P("\t}")
+	g.P("\tx.Dirty.TotalChanges = len(x.", internalName("GetDirtyFieldIndexes"), "())")
+}
+
+// generateSchemaVersionFields 在结构体中生成结构版本的加载状态
+func generateSchemaVersionFields(g *protogen.GeneratedFile, message *protogen.Message) {
+	if getMessageOptions(message).GetSchemaVersion() == 0 {
+		return
+	}
+	g.P("\t// 由投影或聚合结果解码且_v不是当前版本，未执行升级，保存时不写入_v")
+	g.P("\tstaleSchema bool")
+}
+
+// generateUpgradedDirty 生成解码结束后的标脏代码，升级改变了值的字段整体标脏，下次保存时写回
+// 没有字段改变时也记录升级，保存时写入_v，避免每次加载都重新升级
+func generateUpgradedDirty(g *protogen.GeneratedFile, message *protogen.Message) {
+	if getMessageOptions(message).GetSchemaVersion() == 0 {
+		return
+	}
+	keys := make([]string, len(message.Fields))
+	for i, field := range message.Fields {
+		keys[i] = strconv.Quote(getBsonName(field))
+	}
+	g.P("\tif upgraded {")
+	g.P("\t\tx.Dirty.SchemaUpgraded = true")
+	g.P("\t\tfor _, index := range ", ormPackage.Ident("ChangedFields"), "(original, data, ", strings.Join(keys, ", "), ") {")
+	if trackUpgraded(message) {
+		g.P("\t\t\tx.setFieldUpgraded(index)")
+	}
+	g.P("\t\t\tx.setFieldReplaced(index)")
+	g.P("\t\t\tx.", internalName("NotifyFieldChanged"), "(index)")
+	g.P("\t\t}")
+	g.P("\t}")
+}
+
+// generateSchemaVersion 为声明了schema_version的消息生成版本常量和注册升级函数的方法
+func generateSchemaVersion(g *protogen.GeneratedFile, message *protogen.Message) {
+	version := getMessageOptions(message).GetSchemaVersion()
+	if version == 0 {
+		return
+	}
+	structName := message.GoIdent.GoName
+	g.P("// ", structName, "SchemaVersion ", structName, "当前的文档结构版本，写入每个文档的_v")
+	g.P("const ", structName, "SchemaVersion int32 = ", version)
+	g.P()
+	g.P("// Register", structName, "Upgrade 注册把", structName, "文档从from版本升级到from+1的函数，通常在init中调用")
+	g.P("// 加载_v小于", structName, "SchemaVersion的文档时依次调用，没有_v的文档视为版本1；升级改变了值的字段被标脏，下次保存时写回")
+	g.P("func Register", structName, "Upgrade(from int32, fn ", ormPackage.Ident("UpgradeFunc"), ") {")
+	g.P("\t", ormPackage.Ident("RegisterUpgrade"), "(", strconv.Quote(string(message.Desc.FullName())), ", from, fn)")
+	g.P("}")
+	g.P()
+}
+
+// generateMigrate 为需要迁移的消息生成批量改写集合的Migrate方法
+func generateMigrate(g *protogen.GeneratedFile, message *protogen.Message) {
+	if !hasMigrations(message) && getMessageOptions(message).GetSchemaVersion() == 0 {
+		return
+	}
+	structName := message.GoIdent.GoName
+	repoName := structName + "Repository"
+	progress := g.QualifiedGoIdent(ormPackage.Ident("MigrationProgress"))
+	g.P("// Migrate 遍历集合中的全部文档，把仍使用旧键名或旧版本结构的字段改写后保存，返回迁移进度")
+	g.P("// 包括已软删除的文档，不调用生命周期钩子；progress非nil时每处理完一个游标批次调用一次")
+	g.P("func (r *", repoName, ") Migrate(ctx ", contextPackage.Ident("Context"), ", progress func(", progress, "), opts ...*", optionsPackage.Ident("FindOptions"), ") (", progress, ", error) {")
+	g.P("\treturn ", ormPackage.Ident("Migrate"), "(ctx, r.collection, New", structName, ", progress, opts...)")
+	g.P("}")
+	g.P()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"DB/orm/ormpb"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// getFieldOptions 读取字段上的(orm.field)选项，未设置时返回空选项
+func getFieldOptions(field *protogen.Field) *ormpb.FieldOptions {
+	opts := field.Desc.Options()
+	if opts == nil || !proto.HasExtension(opts, ormpb.E_Field) {
+		return &ormpb.FieldOptions{}
+	}
+	return proto.GetExtension(opts, ormpb.E_Field).(*ormpb.FieldOptions)
+}
+
+// getMessageOptions 读取消息上的(orm.message)选项，未设置时返回空选项
+func getMessageOptions(message *protogen.Message) *ormpb.MessageOptions {
+	opts := message.Desc.Options()
+	if opts == nil || !proto.HasExtension(opts, ormpb.E_Message) {
+		return &ormpb.MessageOptions{}
+	}
+	return proto.GetExtension(opts, ormpb.E_Message).(*ormpb.MessageOptions)
+}
+
+// getPrimaryKeyField 返回标记为primary_key的字段，没有则返回nil
+func getPrimaryKeyField(message *protogen.Message) *protogen.Field {
+	for _, field := range message.Fields {
+		if getFieldOptions(field).GetPrimaryKey() {
+			return field
+		}
+	}
+	return nil
+}
+
+// getVersionField 返回标记为version的字段，没有则返回nil
+func getVersionField(message *protogen.Message) *protogen.Field {
+	for _, field := range message.Fields {
+		if getFieldOptions(field).GetVersion() {
+			return field
+		}
+	}
+	return nil
+}
+
+// getAutoCreateTimeField 返回标记为auto_create_time的字段，没有则返回nil
+func getAutoCreateTimeField(message *protogen.Message) *protogen.Field {
+	for _, field := range message.Fields {
+		if getFieldOptions(field).GetAutoCreateTime() {
+			return field
+		}
+	}
+	return nil
+}
+
+// getAutoUpdateTimeField 返回标记为auto_update_time的字段，没有则返回nil
+func getAutoUpdateTimeField(message *protogen.Message) *protogen.Field {
+	for _, field := range message.Fields {
+		if getFieldOptions(field).GetAutoUpdateTime() {
+			return field
+		}
+	}
+	return nil
+}
+
+// getBsonName 返回字段在BSON文档中的键名，主键固定为_id，其余字段可用bson_name指定
+func getBsonName(field *protogen.Field) string {
+	opts := getFieldOptions(field)
+	if opts.GetPrimaryKey() {
+		return "_id"
+	}
+	if opts.GetBsonName() != "" {
+		return opts.GetBsonName()
+	}
+	return getFieldName(field)
+}
+
+// checkBsonNames 校验bson_name和previous_names，同一消息中的键名和别名不能重复
+func checkBsonNames(message *protogen.Message) error {
+	used := map[string]string{}
+	claim := func(field *protogen.Field, key string) error {
+		if key == "" || strings.HasPrefix(key, "$") || strings.Contains(key, ".") {
+			return fmt.Errorf("%s: invalid bson key %q", field.Desc.FullName(), key)
+		}
+		if other, ok := used[key]; ok {
+			return fmt.Errorf("%s: bson key %q is already used by %s", field.Desc.FullName(), key, other)
+		}
+		used[key] = string(field.Desc.Name())
+		return nil
+	}
+	for _, field := range message.Fields {
+		opts := getFieldOptions(field)
+		if opts.GetPrimaryKey() && opts.GetBsonName() != "" {
+			return fmt.Errorf("%s: primary_key field cannot have bson_name", field.Desc.FullName())
+		}
+		if err := claim(field, getBsonName(field)); err != nil {
+			return err
+		}
+	}
+	for _, field := range message.Fields {
+		for _, name := range getFieldOptions(field).GetPreviousNames() {
+			if name == "_id" {
+				return fmt.Errorf("%s: previous_names cannot contain _id", field.Desc.FullName())
+			}
+			if err := claim(field, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkMessageOptions 校验消息上的选项组合是否合法
+func checkMessageOptions(message *protogen.Message) error {
+	if err := checkBsonNames(message); err != nil {
+		return err
+	}
+	var primaryKeys, versions, createTimes, updateTimes int
+	for _, field := range message.Fields {
+		if err := checkFieldRules(field); err != nil {
+			return err
+		}
+		if err := checkIDType(field); err != nil {
+			return err
+		}
+		if err := checkDecimal(field); err != nil {
+			return err
+		}
+		opts := getFieldOptions(field)
+		if opts.GetPrimaryKey() {
+			primaryKeys++
+			if isArrayOrMap(field) || field.Desc.Kind() == protoreflect.MessageKind {
+				return fmt.Errorf("%s: primary_key field must be a scalar", field.Desc.FullName())
+			}
+		}
+		if opts.GetVersion() {
+			versions++
+			if isArrayOrMap(field) || field.Desc.Kind() != protoreflect.Int64Kind {
+				return fmt.Errorf("%s: version field must be int64", field.Desc.FullName())
+			}
+			if opts.GetPrimaryKey() {
+				return fmt.Errorf("%s: field cannot be both primary_key and version", field.Desc.FullName())
+			}
+		}
+		if opts.GetAutoCreateTime() || opts.GetAutoUpdateTime() {
+			if opts.GetAutoCreateTime() {
+				createTimes++
+			}
+			if opts.GetAutoUpdateTime() {
+				updateTimes++
+			}
+			if isArrayOrMap(field) || field.Desc.Kind() != protoreflect.Int64Kind {
+				return fmt.Errorf("%s: auto timestamp field must be int64", field.Desc.FullName())
+			}
+			if opts.GetPrimaryKey() || opts.GetVersion() || (opts.GetAutoCreateTime() && opts.GetAutoUpdateTime()) {
+				return fmt.Errorf("%s: auto timestamp field cannot have other orm options", field.Desc.FullName())
+			}
+		}
+	}
+	if primaryKeys > 1 {
+		return fmt.Errorf("%s: multiple primary_key fields", message.Desc.FullName())
+	}
+	if versions > 1 {
+		return fmt.Errorf("%s: multiple version fields", message.Desc.FullName())
+	}
+	if versions > 0 && primaryKeys == 0 {
+		return fmt.Errorf("%s: version field requires a primary_key field", message.Desc.FullName())
+	}
+	if createTimes > 1 || updateTimes > 1 {
+		return fmt.Errorf("%s: multiple auto timestamp fields of the same kind", message.Desc.FullName())
+	}
+	if (createTimes > 0 || updateTimes > 0) && primaryKeys == 0 {
+		return fmt.Errorf("%s: auto timestamp fields require a primary_key field", message.Desc.FullName())
+	}
+	if getMessageOptions(message).GetCollection() != "" && primaryKeys == 0 {
+		return fmt.Errorf("%s: collection requires a primary_key field", message.Desc.FullName())
+	}
+	if getMessageOptions(message).GetSchemaVersion() > 0 {
+		if primaryKeys == 0 {
+			return fmt.Errorf("%s: schema_version requires a primary_key field", message.Desc.FullName())
+		}
+		for _, field := range message.Fields {
+			if getBsonName(field) == "_v" || slices.Contains(getFieldOptions(field).GetPreviousNames(), "_v") {
+				return fmt.Errorf("%s: field name _v is reserved by schema_version", field.Desc.FullName())
+			}
+		}
+	}
+	if getMessageOptions(message).GetSoftDelete() {
+		if primaryKeys == 0 {
+			return fmt.Errorf("%s: soft_delete requires a primary_key field", message.Desc.FullName())
+		}
+		for _, field := range message.Fields {
+			if getBsonName(field) == "deleted_at" || slices.Contains(getFieldOptions(field).GetPreviousNames(), "deleted_at") {
+				return fmt.Errorf("%s: field name deleted_at is reserved by soft_delete", field.Desc.FullName())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generatePartialFields 在结构体中生成部分加载的状态字段
+func generatePartialFields(g *protogen.GeneratedFile, message *protogen.Message) {
+	bitmapSize := (len(message.Fields) + 63) / 64
+	g.P("\t// 部分加载状态，partial为false时所有字段都已加载")
+	g.P("\tpartial bool")
+	if bitmapSize == 1 {
+		g.P("\tloadedBitmap uint64")
+	} else {
+		g.P("\tloadedBitmap [", bitmapSize, "]uint64")
+	}
+}
+
+// generatePartialReset 生成完整加载时清除部分加载状态的代码
+func generatePartialReset(g *protogen.GeneratedFile, message *protogen.Message) {
+	g.P("\tx.partial = false")
+	generateLoadedReset(g, message)
+}
+
+// generateLoadedReset 生成清除已加载字段位图的代码
+func generateLoadedReset(g *protogen.GeneratedFile, message *protogen.Message) {
+	bitmapSize := (len(message.Fields) + 63) / 64
+	if bitmapSize == 1 {
+		g.P("\tx.loadedBitmap = 0")
+	} else {
+		g.P("\tx.loadedBitmap = [", bitmapSize, "]uint64{}")
+	}
+}
+
+// generatePartialMethods 生成IsPartial、Is<Field>Loaded、MarkLoaded以及保存前的检查
+func generatePartialMethods(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	bitmapSize := (len(message.Fields) + 63) / 64
+	fieldMask := g.QualifiedGoIdent(fieldmaskPackage.Ident("FieldMask"))
+
+	g.P("// isFieldLoaded 检查指定字段是否已加载")
+	g.P("func (x *", structName, ") isFieldLoaded(fieldIndex int) bool {")
+	g.P("\tif x == nil || !x.partial {")
+	g.P("\t\treturn true")
+	g.P("\t}")
+	if bitmapSize == 1 {
+		g.P("\treturn (x.loadedBitmap & (1 << uint(fieldIndex))) != 0")
+	} else {
+		g.P("\treturn (x.loadedBitmap[fieldIndex/64] & (1 << uint(fieldIndex%64))) != 0")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// setFieldLoaded 标记指定字段已加载，整体赋值后字段的值不再依赖数据库")
+	g.P("func (x *", structName, ") setFieldLoaded(fieldIndex int) {")
+	if bitmapSize == 1 {
+		g.P("\tx.loadedBitmap |= (1 << uint(fieldIndex))")
+	} else {
+		g.P("\tx.loadedBitmap[fieldIndex/64] |= (1 << uint(fieldIndex%64))")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// IsPartial 是否只加载了部分字段")
+	g.P("func (x *", structName, ") IsPartial() bool {")
+	g.P("\tif x == nil {")
+	g.P("\t\treturn false")
+	g.P("\t}")
+	generateLock(g, false)
+	g.P("\treturn x.partial")
+	g.P("}")
+	g.P()
+
+	for _, field := range message.Fields {
+		constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+		g.P("// Is", field.GoName, "Loaded 检查", field.GoName, "字段是否已加载，未加载时Getter返回零值")
+		if isMessageField(field) {
+			g.P("// 只加载了嵌套消息的部分字段时返回false，子字段的加载状态由子对象判断")
+		}
+		g.P("func (x *", structName, ") Is", field.GoName, "Loaded() bool {")
+		generateLock(g, false)
+		g.P("\treturn x.isFieldLoaded(", constName, ")")
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// MarkLoaded 标记对象只加载了mask中的字段，使用投影查询解码后调用")
+	g.P("// 主键总是随查询返回，视为已加载；嵌套路径使子消息进入部分加载状态")
+	generateLockedMethod(g, structName, "MarkLoaded", "mask *"+fieldMask, "mask", "", true, func() {
+		g.P("\tif x == nil {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		generateLoadedReset(g, message)
+		g.P("\tx.partial = true")
+		if pkField := getPrimaryKeyField(message); pkField != nil {
+			g.P("\tx.setFieldLoaded(", structName, pkField.GoName, "FieldIndex)")
+		}
+		hasMessage := false
+		for _, field := range message.Fields {
+			if isMessageField(field) {
+				hasMessage = true
+				fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+				g.P("\tvar ", fieldName, "Paths []string")
+			}
+		}
+		g.P("\tfor _, path := range mask.GetPaths() {")
+		if hasMessage {
+			g.P("\t\tname, rest, nested := ", stringsPackage.Ident("Cut"), "(path, \".\")")
+		} else {
+			g.P("\t\tname, _, _ := ", stringsPackage.Ident("Cut"), "(path, \".\")")
+		}
+		g.P("\t\tswitch name {")
+		for _, field := range message.Fields {
+			constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+			g.P("\t\tcase \"", getFieldName(field), "\":")
+			if isMessageField(field) {
+				fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+				g.P("\t\t\tif nested {")
+				g.P("\t\t\t\t", fieldName, "Paths = append(", fieldName, "Paths, rest)")
+				g.P("\t\t\t} else {")
+				g.P("\t\t\t\tx.setFieldLoaded(", constName, ")")
+				g.P("\t\t\t}")
+				continue
+			}
+			g.P("\t\t\tx.setFieldLoaded(", constName, ")")
+		}
+		g.P("\t\t}")
+		g.P("\t}")
+		for _, field := range message.Fields {
+			if !isMessageField(field) {
+				continue
+			}
+			fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+			constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+			g.P("\tif !x.isFieldLoaded(", constName, ") && x.", fieldName, " != nil {")
+			g.P("\t\tx.", fieldName, ".", internalName("MarkLoaded"), "(&", fieldMask, "{Paths: ", fieldName, "Paths})")
+			g.P("\t}")
+		}
+	})
+
+	g.P("// checkLoaded 检查增量更新是否依赖未加载的数据")
+	g.P("// 数组整体写入，对未加载的数组追加或修改元素会覆盖数据库中的原有元素")
+	g.P("// 字典的键不能用作点分路径时整个字典写入，未加载时同样会覆盖原有的键")
+	g.P("func (x *", structName, ") checkLoaded(prefix string) error {")
+	g.P("\tif x == nil || !x.partial {")
+	g.P("\t\treturn nil")
+	g.P("\t}")
+	if versionField := getVersionField(message); versionField != nil {
+		constName := fmt.Sprintf("%s%sFieldIndex", structName, versionField.GoName)
+		g.P("\t// 版本号未加载时过滤条件中的版本号不可信")
+		g.P("\tif !x.isFieldLoaded(", constName, ") {")
+		g.P("\t\treturn &", ormPackage.Ident("UnloadedFieldError"), "{Path: prefix + \"", getBsonName(versionField), "\"}")
+		g.P("\t}")
+	}
+	for _, field := range message.Fields {
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+		bsonName := getBsonName(field)
+		switch {
+		case field.Desc.IsList():
+			g.P("\tif x.isFieldDirty(", constName, ") && !x.isFieldLoaded(", constName, ") {")
+			g.P("\t\treturn &", ormPackage.Ident("UnloadedFieldError"), "{Path: prefix + \"", bsonName, "\"}")
+			g.P("\t}")
+		case field.Desc.IsMap():
+			// 整体赋值会同时标记为已加载，这里只拦截按键修改引起的整体写入
+			g.P("\tif x.isFieldReplaced(", constName, ") && !x.isFieldLoaded(", constName, ") {")
+			g.P("\t\treturn &", ormPackage.Ident("UnloadedFieldError"), "{Path: prefix + \"", bsonName, "\"}")
+			g.P("\t}")
+		case isMessageField(field):
+			g.P("\tif x.isFieldDirty(", constName, ") && !x.isFieldReplaced(", constName, ") {")
+			g.P("\t\tif err := x.", fieldName, ".checkLoaded(prefix + \"", bsonName, ".\"); err != nil {")
+			g.P("\t\t\treturn err")
+			g.P("\t\t}")
+			g.P("\t}")
+		}
+	}
+	g.P("\treturn nil")
+	g.P("}")
+	g.P()
+}
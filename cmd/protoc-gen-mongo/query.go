@@ -0,0 +1,92 @@
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// generateFieldPaths 生成字段路径类型和<Message>Fields变量，用于构建类型安全的查询条件
+func generateFieldPaths(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	pathsName := structName + "FieldPaths"
+
+	g.P("// ", pathsName, " ", structName, "各字段在文档中的路径，比较方法只接受字段对应的Go类型")
+	g.P("type ", pathsName, " struct {")
+	g.P("\t", ormPackage.Ident("MessageField"))
+	for _, field := range message.Fields {
+		g.P("\t", field.GoName, " ", fieldPathType(g, message, field))
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// New", pathsName, " 创建以prefix为前缀的字段路径，prefix为空或以点结尾")
+	g.P("// 在ElemMatch中使用空前缀构建相对于数组元素的条件")
+	g.P("func New", pathsName, "(prefix string) ", pathsName, " {")
+	g.P("\treturn ", pathsName, "{")
+	g.P("\t\tMessageField: ", ormPackage.Ident("NewMessageField"), "(", stringsPackage.Ident("TrimSuffix"), "(prefix, \".\")),")
+	for _, field := range message.Fields {
+		bsonName := getBsonName(field)
+		switch {
+		case field.Desc.IsMap():
+			keyType, valueType := getMapTypes(field)
+			g.P("\t\t", field.GoName, ": ", ormPackage.Ident("NewMapField"), "[", keyType, ", ", valueType, "](prefix + \"", bsonName, "\"),")
+		case field.Desc.IsList():
+			g.P("\t\t", field.GoName, ": ", ormPackage.Ident("NewListField"), "[", getElementType(field), "](prefix + \"", bsonName, "\"),")
+		case isMessageField(field) && !isRecursiveField(message, field):
+			g.P("\t\t", field.GoName, ": New", field.Message.GoIdent.GoName, "FieldPaths(prefix + \"", bsonName, ".\"),")
+		case isMessageField(field):
+			g.P("\t\t", field.GoName, ": ", ormPackage.Ident("NewMessageField"), "(prefix + \"", bsonName, "\"),")
+		case field.Desc.Kind() == protoreflect.StringKind && !hasCustomType(field):
+			g.P("\t\t", field.GoName, ": ", ormPackage.Ident("NewStringField"), "(prefix + \"", bsonName, "\"),")
+		default:
+			g.P("\t\t", field.GoName, ": ", ormPackage.Ident("NewField"), "[", getGoType(field), "](prefix + \"", bsonName, "\"),")
+		}
+	}
+	g.P("\t}")
+	g.P("}")
+	g.P()
+
+	g.P("// ", structName, "Fields ", structName, "作为顶层文档时的字段路径")
+	g.P("var ", structName, "Fields = New", pathsName, "(\"\")")
+	g.P()
+}
+
+// fieldPathType 返回字段路径的类型
+func fieldPathType(g *protogen.GeneratedFile, message *protogen.Message, field *protogen.Field) string {
+	switch {
+	case field.Desc.IsMap():
+		keyType, valueType := getMapTypes(field)
+		return g.QualifiedGoIdent(ormPackage.Ident("MapField")) + "[" + keyType + ", " + valueType + "]"
+	case field.Desc.IsList():
+		return g.QualifiedGoIdent(ormPackage.Ident("ListField")) + "[" + getElementType(field) + "]"
+	case isMessageField(field) && !isRecursiveField(message, field):
+		return field.Message.GoIdent.GoName + "FieldPaths"
+	case isMessageField(field):
+		// 递归引用的消息不能按值内嵌，只提供字段本身的路径
+		return g.QualifiedGoIdent(ormPackage.Ident("MessageField"))
+	case field.Desc.Kind() == protoreflect.StringKind && !hasCustomType(field):
+		return g.QualifiedGoIdent(ormPackage.Ident("StringField"))
+	}
+	return g.QualifiedGoIdent(ormPackage.Ident("Field")) + "[" + getGoType(field) + "]"
+}
+
+// isRecursiveField 判断嵌套消息字段是否会经由单值消息字段引用回当前消息
+func isRecursiveField(message *protogen.Message, field *protogen.Field) bool {
+	visited := map[*protogen.Message]bool{}
+	var reaches func(m *protogen.Message) bool
+	reaches = func(m *protogen.Message) bool {
+		if m == message {
+			return true
+		}
+		if visited[m] {
+			return false
+		}
+		visited[m] = true
+		for _, f := range m.Fields {
+			if isMessageField(f) && reaches(f.Message) {
+				return true
+			}
+		}
+		return false
+	}
+	return reaches(field.Message)
+}
@@ -0,0 +1,747 @@
+package main
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateRepositoryFile 为带主键的消息生成MongoDB仓储，没有主键的消息只作为嵌套文档使用
+func generateRepositoryFile(gen *protogen.Plugin, file *protogen.File) {
+	var messages []*protogen.Message
+	for _, message := range file.Messages {
+		if getPrimaryKeyField(message) != nil {
+			messages = append(messages, message)
+		}
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	filename := file.GeneratedFilenamePrefix + "_repository.pb.go"
+	g := gen.NewGeneratedFile(filename, file.GoImportPath)
+	generateHeader(gen, g, file)
+
+	for _, message := range messages {
+		importCustomTypes(g, message)
+		generateDocumentMethods(g, message)
+		generateRepository(g, message)
+		generateSchemaVersion(g, message)
+		generateMigrate(g, message)
+		generateSchemaValidator(g, message)
+	}
+}
+
+// generateDocumentMethods 生成orm.Document接口的实现，供Repository和orm.UnitOfWork共用
+func generateDocumentMethods(g *protogen.GeneratedFile, message *protogen.Message) {
+	structName := message.GoIdent.GoName
+	pkField := getPrimaryKeyField(message)
+	pkName := strings.ToLower(pkField.GoName[:1]) + pkField.GoName[1:]
+	versionField := getVersionField(message)
+
+	g.P("var _ ", ormPackage.Ident("Document"), " = (*", structName, ")(nil)")
+	g.P()
+
+	g.P("// PrimaryKey 返回主键值")
+	g.P("func (x *", structName, ") PrimaryKey() interface{} {")
+	generateLock(g, false)
+	g.P("\treturn x.", pkName)
+	g.P("}")
+	g.P()
+
+	g.P("// UpdateModel 根据脏标记构建UpdateOne模型，没有变更时返回nil")
+	if versionField != nil {
+		g.P("// 过滤条件包含当前版本号，更新中对版本号自增")
+	}
+	updateTimeField := getAutoUpdateTimeField(message)
+	if updateTimeField != nil {
+		g.P("// 更新中写入当前时间作为", updateTimeField.GoName)
+	}
+	schemaVersion := getMessageOptions(message).GetSchemaVersion()
+	if schemaVersion > 0 {
+		g.P("// 更新中写入当前的文档结构版本")
+	}
+	softDelete := getMessageOptions(message).GetSoftDelete()
+	if softDelete {
+		g.P("// 过滤条件排除已软删除的文档，保存已删除的实体不会写入")
+	}
+	modelType := "*" + g.QualifiedGoIdent(mongoPackage.Ident("UpdateOneModel"))
+	buildModel := func() {
+		g.P("\tupdate := x.", internalName("BuildUpdate"), "()")
+		if schemaVersion > 0 {
+			// 升级没有改变字段时仍需写入_v
+			g.P("\tif update == nil {")
+			g.P("\t\tif x.Dirty == nil || !x.Dirty.SchemaUpgraded {")
+			g.P("\t\t\treturn nil")
+			g.P("\t\t}")
+			g.P("\t\tupdate = ", bsonPackage.Ident("M"), "{}")
+			g.P("\t}")
+		} else {
+			g.P("\tif update == nil {")
+			g.P("\t\treturn nil")
+			g.P("\t}")
+		}
+		generateSetMap := func(indent string) {
+			g.P(indent, "set, _ := update[\"$set\"].(", bsonPackage.Ident("M"), ")")
+			g.P(indent, "if set == nil {")
+			g.P(indent, "\tset = ", bsonPackage.Ident("M"), "{}")
+			g.P(indent, "\tupdate[\"$set\"] = set")
+			g.P(indent, "}")
+		}
+		if updateTimeField != nil {
+			generateSetMap("\t")
+			g.P("\tset[\"", getBsonName(updateTimeField), "\"] = now")
+		}
+		if schemaVersion > 0 {
+			// 由投影结果解码且_v不是当前版本的文档未执行升级，不能写入_v
+			g.P("\tif !x.staleSchema {")
+			if updateTimeField == nil {
+				generateSetMap("\t\t")
+			}
+			g.P("\t\tset[", ormPackage.Ident("SchemaVersionField"), "] = ", structName, "SchemaVersion")
+			g.P("\t}")
+		}
+		if versionField != nil {
+			versionName := strings.ToLower(versionField.GoName[:1]) + versionField.GoName[1:]
+			versionBsonName := getBsonName(versionField)
+			g.P("\tfilter := ", bsonPackage.Ident("D"), "{")
+			g.P("\t\t{Key: \"_id\", Value: x.", pkName, "},")
+			g.P("\t\t{Key: \"", versionBsonName, "\", Value: x.", versionName, "},")
+			if softDelete {
+				g.P("\t\t{Key: ", ormPackage.Ident("SoftDeleteField"), ", Value: nil},")
+			}
+			g.P("\t}")
+			g.P("\tinc, _ := update[\"$inc\"].(", bsonPackage.Ident("M"), ")")
+			g.P("\tif inc == nil {")
+			g.P("\t\tinc = ", bsonPackage.Ident("M"), "{}")
+			g.P("\t\tupdate[\"$inc\"] = inc")
+			g.P("\t}")
+			g.P("\tinc[\"", versionBsonName, "\"] = int64(1)")
+		} else if softDelete {
+			g.P("\tfilter := ", bsonPackage.Ident("D"), "{{Key: \"_id\", Value: x.", pkName, "}, {Key: ", ormPackage.Ident("SoftDeleteField"), ", Value: nil}}")
+		} else {
+			g.P("\tfilter := ", bsonPackage.Ident("D"), "{{Key: \"_id\", Value: x.", pkName, "}}")
+		}
+		g.P("\treturn ", mongoPackage.Ident("NewUpdateOneModel"), "().SetFilter(filter).SetUpdate(update)")
+	}
+	if updateTimeField == nil {
+		generateLockedMethod(g, structName, "UpdateModel", "", "", modelType, false, buildModel)
+	} else {
+		generateLockedMethod(g, structName, "UpdateModel", "", "", modelType, false, func() {
+			g.P("\treturn x.buildUpdateModel(", timePackage.Ident("Now"), "().UnixMilli())")
+		})
+		g.P("// buildUpdateModel 构建UpdateOne模型，now为写入", updateTimeField.GoName, "的Unix毫秒时间")
+		g.P("func (x *", structName, ") buildUpdateModel(now int64) ", modelType, " {")
+		buildModel()
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// TakeUpdateModel 构建增量更新模型并取出脏状态，没有变更时返回nil")
+	g.P("// 只修改了主键或版本号等不写入更新的字段时清除脏标记，避免IsDirty一直为真")
+	g.P("// 更新在取出时序列化，与之后对实体的修改无关；写入失败时调用RestoreSnapshot合并回去")
+	g.P("// 部分加载的对象修改了未加载的数组时返回*orm.UnloadedFieldError，脏状态保持不变")
+	takeResults := "(" + modelType + ", " + g.QualifiedGoIdent(ormPackage.Ident("DirtySnapshot")) + ", error)"
+	generateLockedMethod(g, structName, "TakeUpdateModel", "", "", takeResults, true, func() {
+		if updateTimeField != nil {
+			g.P("\tnow := ", timePackage.Ident("Now"), "().UnixMilli()")
+			g.P("\tmodel := x.buildUpdateModel(now)")
+		} else {
+			g.P("\tmodel := x.", internalName("UpdateModel"), "()")
+		}
+		g.P("\tif model == nil {")
+		g.P("\t\tx.", internalName("ResetDirty"), "()")
+		g.P("\t\treturn nil, nil, nil")
+		g.P("\t}")
+		g.P("\tif err := x.checkLoaded(\"\"); err != nil {")
+		g.P("\t\treturn nil, nil, err")
+		g.P("\t}")
+		g.P("\tupdate, err := ", bsonPackage.Ident("Marshal"), "(model.Update)")
+		g.P("\tif err != nil {")
+		g.P("\t\treturn nil, nil, err")
+		g.P("\t}")
+		g.P("\tmodel.SetUpdate(", bsonPackage.Ident("Raw"), "(update))")
+		if updateTimeField != nil {
+			// 更新时间随本次更新写入，同步内存中的值且不产生脏标记
+			updateTimeName := strings.ToLower(updateTimeField.GoName[:1]) + updateTimeField.GoName[1:]
+			g.P("\tx.", updateTimeName, " = now")
+			g.P("\tx.setFieldLoaded(", structName, updateTimeField.GoName, "FieldIndex)")
+		}
+		g.P("\treturn model, x.", internalName("TakeDirty"), "(), nil")
+	})
+
+	if audit {
+		fieldChange := g.QualifiedGoIdent(ormPackage.Ident("FieldChange"))
+		g.P("// TakeAuditedUpdateModel 与TakeUpdateModel相同，同时返回本次更新的字段修改及原值，用于写入审计记录")
+		g.P("func (x *", structName, ") TakeAuditedUpdateModel() (", modelType, ", ", ormPackage.Ident("DirtySnapshot"), ", []", fieldChange, ", error) {")
+		generateLock(g, true)
+		g.P("\tchanges := x.collectChanges(\"\", nil)")
+		g.P("\tmodel, snapshot, err := x.", internalName("TakeUpdateModel"), "()")
+		g.P("\tif model == nil || err != nil {")
+		g.P("\t\treturn nil, nil, nil, err")
+		g.P("\t}")
+		g.P("\treturn model, snapshot, changes, nil")
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// RestoreSnapshot 写入失败时把TakeUpdateModel取出的脏状态合并回实体")
+	g.P("func (x *", structName, ") RestoreSnapshot(snapshot ", ormPackage.Ident("DirtySnapshot"), ") {")
+	g.P("\tif s, ok := snapshot.(*", structName, "DirtySnapshot); ok {")
+	g.P("\t\tx.RestoreDirty(s)")
+	g.P("\t}")
+	g.P("}")
+	g.P()
+
+	g.P("// MarkSaved 增量更新写入成功后调用，同步版本号，脏状态已在TakeUpdateModel时取出")
+	g.P("func (x *", structName, ") MarkSaved() {")
+	if versionField != nil {
+		generateLock(g, true)
+		versionName := strings.ToLower(versionField.GoName[:1]) + versionField.GoName[1:]
+		g.P("\t// 版本号已在数据库中自增，这里同步内存中的值且不产生脏标记")
+		g.P("\tx.", versionName, "++")
+	}
+	g.P("}")
+	g.P()
+
+	if versionField != nil {
+		versionName := strings.ToLower(versionField.GoName[:1]) + versionField.GoName[1:]
+		g.P("var _ ", ormPackage.Ident("AppliedFilterer"), " = (*", structName, ")(nil)")
+		g.P()
+		g.P("// AppliedFilter 返回增量更新写入后文档应满足的过滤条件，即主键加上自增后的版本号")
+		g.P("func (x *", structName, ") AppliedFilter() ", bsonPackage.Ident("D"), " {")
+		generateLock(g, false)
+		g.P("\treturn ", bsonPackage.Ident("D"), "{")
+		g.P("\t\t{Key: \"_id\", Value: x.", pkName, "},")
+		g.P("\t\t{Key: \"", getBsonName(versionField), "\", Value: x.", versionName, " + 1},")
+		g.P("\t}")
+		g.P("}")
+		g.P()
+
+		g.P("// versionConflict 构造保存时版本不一致返回的错误，在写入前调用，记录取出更新时的主键和版本号")
+		g.P("func (x *", structName, ") versionConflict(collection string) *", ormPackage.Ident("VersionConflictError"), " {")
+		generateLock(g, false)
+		g.P("\treturn &", ormPackage.Ident("VersionConflictError"), "{Collection: collection, ID: x.", pkName, ", Version: x.", versionName, "}")
+		g.P("}")
+		g.P()
+	}
+
+	generateInsertTimestamps(g, message, structName)
+	generateEnsurePrimaryKey(g, message, structName)
+}
+
+// generateInsertTimestamps 生成插入前写入创建时间和更新时间的方法，没有自动时间字段时不生成
+func generateInsertTimestamps(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	createTimeField := getAutoCreateTimeField(message)
+	updateTimeField := getAutoUpdateTimeField(message)
+	if createTimeField == nil && updateTimeField == nil {
+		return
+	}
+	g.P("// stampInsertTimes 插入前写入自动时间字段，已设置的创建时间保留，不产生脏标记")
+	g.P("func (x *", structName, ") stampInsertTimes() {")
+	generateLock(g, true)
+	g.P("\tnow := ", timePackage.Ident("Now"), "().UnixMilli()")
+	if createTimeField != nil {
+		name := strings.ToLower(createTimeField.GoName[:1]) + createTimeField.GoName[1:]
+		g.P("\tif x.", name, " == 0 {")
+		g.P("\t\tx.", name, " = now")
+		g.P("\t}")
+	}
+	if updateTimeField != nil {
+		name := strings.ToLower(updateTimeField.GoName[:1]) + updateTimeField.GoName[1:]
+		g.P("\tx.", name, " = now")
+	}
+	g.P("}")
+	g.P()
+}
+
+func generateRepository(g *protogen.GeneratedFile, message *protogen.Message) {
+	structName := message.GoIdent.GoName
+	repoName := structName + "Repository"
+	pkType := getGoType(getPrimaryKeyField(message))
+	versionField := getVersionField(message)
+	softDelete := getMessageOptions(message).GetSoftDelete()
+
+	g.P("// ", repoName, " ", structName, "的MongoDB仓储，按脏标记增量保存")
+	if softDelete {
+		g.P("// 启用了软删除，Delete只写入deleted_at，查询默认排除已删除的文档")
+	}
+	g.P("type ", repoName, " struct {")
+	g.P("\tcollection *", mongoPackage.Ident("Collection"))
+	g.P("\tunitOfWork *", ormPackage.Ident("UnitOfWork"), " // 非nil时加载和插入的实体登记到工作单元")
+	if softDelete {
+		g.P("\tdeleted ", ormPackage.Ident("DeletedScope"), " // 查询时如何对待已删除的文档")
+	}
+	if audit {
+		g.P("\tauditor *", ormPackage.Ident("Auditor"), " // 非nil时Save在同一事务中写入审计记录")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// New", repoName, " 创建", structName, "仓储")
+	g.P("func New", repoName, "(collection *", mongoPackage.Ident("Collection"), ") *", repoName, " {")
+	g.P("\treturn &", repoName, "{collection: collection}")
+	g.P("}")
+	g.P()
+
+	g.P("// WithUnitOfWork 返回绑定到工作单元的仓储副本")
+	g.P("// 绑定后FindByID优先返回工作单元中已跟踪的实例，同一文档在一次请求中只对应一个对象")
+	g.P("func (r *", repoName, ") WithUnitOfWork(uow *", ormPackage.Ident("UnitOfWork"), ") *", repoName, " {")
+	g.P("\tc := *r")
+	g.P("\tc.unitOfWork = uow")
+	g.P("\treturn &c")
+	g.P("}")
+	g.P()
+
+	if audit {
+		g.P("// WithAuditor 返回Save时写入审计记录的仓储副本，审计记录与更新在同一事务中提交")
+		g.P("// 操作者通过orm.WithActor放入context")
+		g.P("func (r *", repoName, ") WithAuditor(auditor *", ormPackage.Ident("Auditor"), ") *", repoName, " {")
+		g.P("\tc := *r")
+		g.P("\tc.auditor = auditor")
+		g.P("\treturn &c")
+		g.P("}")
+		g.P()
+	}
+
+	if softDelete {
+		g.P("// WithDeleted 返回查询时包含已删除文档的仓储副本")
+		g.P("func (r *", repoName, ") WithDeleted() *", repoName, " {")
+		g.P("\tc := *r")
+		g.P("\tc.deleted = ", ormPackage.Ident("IncludeDeleted"))
+		g.P("\treturn &c")
+		g.P("}")
+		g.P()
+
+		g.P("// OnlyDeleted 返回查询时只返回已删除文档的仓储副本")
+		g.P("func (r *", repoName, ") OnlyDeleted() *", repoName, " {")
+		g.P("\tc := *r")
+		g.P("\tc.deleted = ", ormPackage.Ident("OnlyDeleted"))
+		g.P("\treturn &c")
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// Collection 返回底层集合")
+	g.P("func (r *", repoName, ") Collection() *", mongoPackage.Ident("Collection"), " {")
+	g.P("\treturn r.collection")
+	g.P("}")
+	g.P()
+
+	g.P("// Insert 插入完整文档，插入前取出脏状态，失败时合并回实体")
+	if hasIDType(getPrimaryKeyField(message)) {
+		g.P("// 主键为零值时先生成新的主键")
+	}
+	g.P("// 部分加载的对象不能插入，返回orm.ErrPartialDocument；实现了orm.BeforeInserter、orm.AfterInserter时在写入前后调用")
+	g.P("func (r *", repoName, ") Insert(ctx ", contextPackage.Ident("Context"), ", x *", structName, ") error {")
+	g.P("\tif x.IsPartial() {")
+	g.P("\t\treturn ", ormPackage.Ident("ErrPartialDocument"))
+	g.P("\t}")
+	if hasIDType(getPrimaryKeyField(message)) {
+		g.P("\tx.EnsurePrimaryKey()")
+	}
+	generateHookCall(g, "BeforeInserter", "BeforeInsert(ctx)", "err")
+	if getAutoCreateTimeField(message) != nil || getAutoUpdateTimeField(message) != nil {
+		g.P("\tx.stampInsertTimes()")
+	}
+	g.P("\tsnapshot := x.TakeDirty()")
+	g.P("\tif _, err := r.collection.InsertOne(ctx, x); err != nil {")
+	g.P("\t\tx.RestoreDirty(snapshot)")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\tif r.unitOfWork != nil {")
+	g.P("\t\tif err := r.unitOfWork.Register(r.collection, x); err != nil {")
+	g.P("\t\t\treturn err")
+	g.P("\t\t}")
+	g.P("\t}")
+	generateHookCall(g, "AfterInserter", "AfterInsert(ctx)", "err")
+	g.P("\treturn nil")
+	g.P("}")
+	g.P()
+
+	g.P("// FindByID 按主键加载文档，返回的对象没有脏标记")
+	g.P("// 绑定工作单元时优先返回已跟踪的实例，该实例可能带有尚未保存的修改")
+	g.P("// 新加载的实体实现了orm.AfterLoader时解码后调用AfterLoad")
+	g.P("func (r *", repoName, ") FindByID(ctx ", contextPackage.Ident("Context"), ", id ", pkType, ") (*", structName, ", error) {")
+	g.P("\tif r.unitOfWork != nil {")
+	g.P("\t\tif doc, ok := r.unitOfWork.Lookup(r.collection, id); ok {")
+	g.P("\t\t\tif x, ok := doc.(*", structName, "); ok {")
+	g.P("\t\t\t\treturn x, nil")
+	g.P("\t\t\t}")
+	g.P("\t\t}")
+	g.P("\t}")
+	g.P("\tx := New", structName, "()")
+	generateIDFilter(g, softDelete)
+	g.P("\tif err := r.collection.FindOne(ctx, filter).Decode(x); err != nil {")
+	g.P("\t\treturn nil, err")
+	g.P("\t}")
+	generateHookCall(g, "AfterLoader", "AfterLoad(ctx)", "nil, err")
+	g.P("\tif r.unitOfWork != nil {")
+	g.P("\t\tif err := r.unitOfWork.Register(r.collection, x); err != nil {")
+	g.P("\t\t\treturn nil, err")
+	g.P("\t\t}")
+	g.P("\t}")
+	g.P("\treturn x, nil")
+	g.P("}")
+	g.P()
+
+	g.P("// FindByIDPartial 按主键只加载mask中的字段，返回的对象处于部分加载状态")
+	g.P("// 未加载字段的Getter返回零值，可用Is<Field>Loaded判断；保存时若更新依赖未加载的数据则返回错误")
+	g.P("// 绑定工作单元时优先返回已跟踪的完整实例，部分加载的对象不会登记到工作单元")
+	g.P("func (r *", repoName, ") FindByIDPartial(ctx ", contextPackage.Ident("Context"), ", id ", pkType, ", mask *", fieldmaskPackage.Ident("FieldMask"), ") (*", structName, ", error) {")
+	g.P("\tif r.unitOfWork != nil {")
+	g.P("\t\tif doc, ok := r.unitOfWork.Lookup(r.collection, id); ok {")
+	g.P("\t\t\tif x, ok := doc.(*", structName, "); ok {")
+	g.P("\t\t\t\treturn x, nil")
+	g.P("\t\t\t}")
+	g.P("\t\t}")
+	g.P("\t}")
+	if versionField != nil {
+		g.P("\tif len(mask.GetPaths()) > 0 {")
+		g.P("\t\t// 版本号参与保存时的过滤条件，总是一起加载")
+		g.P("\t\tmask = &", fieldmaskPackage.Ident("FieldMask"), "{Paths: append(", slicesPackage.Ident("Clone"), "(mask.GetPaths()), \"", getFieldName(versionField), "\")}")
+		g.P("\t}")
+	}
+	g.P("\tprojection, err := ", structName, "Projection(mask)")
+	g.P("\tif err != nil {")
+	g.P("\t\treturn nil, err")
+	g.P("\t}")
+	g.P("\tx := New", structName, "()")
+	generateIDFilter(g, softDelete)
+	g.P("\topts := ", optionsPackage.Ident("FindOne"), "()")
+	g.P("\tif projection != nil {")
+	g.P("\t\topts.SetProjection(projection)")
+	g.P("\t}")
+	if getMessageOptions(message).GetSchemaVersion() > 0 {
+		// 投影结果可能缺少升级函数依赖的字段，不执行结构升级
+		g.P("\traw, err := r.collection.FindOne(ctx, filter, opts).Raw()")
+		g.P("\tif err != nil {")
+		g.P("\t\treturn nil, err")
+		g.P("\t}")
+		g.P("\tif projection != nil {")
+		g.P("\t\terr = x.UnmarshalProjectedBSON(raw)")
+		g.P("\t} else {")
+		g.P("\t\terr = x.UnmarshalBSON(raw)")
+		g.P("\t}")
+		g.P("\tif err != nil {")
+		g.P("\t\treturn nil, err")
+		g.P("\t}")
+	} else {
+		g.P("\tif err := r.collection.FindOne(ctx, filter, opts).Decode(x); err != nil {")
+		g.P("\t\treturn nil, err")
+		g.P("\t}")
+	}
+	g.P("\tif projection != nil {")
+	g.P("\t\tx.MarkLoaded(mask)")
+	g.P("\t}")
+	generateHookCall(g, "AfterLoader", "AfterLoad(ctx)", "nil, err")
+	g.P("\treturn x, nil")
+	g.P("}")
+	g.P()
+
+	g.P("// FindPage 按sort字段加_id做键集分页，token为空时返回第一页，size不大于0时不限制条数")
+	g.P("// 返回的NextToken为空表示没有更多数据；令牌与排序方式绑定，排序不一致时返回orm.ErrInvalidPageToken")
+	g.P("// 绑定工作单元时与FindByID一样优先返回已跟踪的实例，新加载的实体登记到工作单元")
+	g.P("func (r *", repoName, ") FindPage(ctx ", contextPackage.Ident("Context"), ", filter ", ormPackage.Ident("Filter"), ", sort ", ormPackage.Ident("Sort"), ", size int64, token string) (*", ormPackage.Ident("Page"), "[*", structName, "], error) {")
+	g.P("\tkeyset, err := ", ormPackage.Ident("KeysetFilter"), "(sort, token)")
+	g.P("\tif err != nil {")
+	g.P("\t\treturn nil, err")
+	g.P("\t}")
+	g.P("\topts := ", optionsPackage.Ident("Find"), "().SetSort(sort.D())")
+	g.P("\tif size > 0 {")
+	g.P("\t\topts.SetLimit(size)")
+	g.P("\t}")
+	g.P("\tit, err := r.Iterate(ctx, ", ormPackage.Ident("And"), "(filter, keyset), opts)")
+	g.P("\tif err != nil {")
+	g.P("\t\treturn nil, err")
+	g.P("\t}")
+	g.P("\tdefer it.Close(ctx)")
+	g.P("\tpage := &", ormPackage.Ident("Page"), "[*", structName, "]{}")
+	g.P("\tvar last ", bsonPackage.Ident("Raw"))
+	g.P("\tfor it.Next(ctx) {")
+	g.P("\t\tx, err := r.track(it.Value())")
+	g.P("\t\tif err != nil {")
+	g.P("\t\t\treturn nil, err")
+	g.P("\t\t}")
+	g.P("\t\tpage.Items = append(page.Items, x)")
+	g.P("\t\tlast = append(last[:0], it.Raw()...)")
+	g.P("\t}")
+	g.P("\tif err := it.Err(); err != nil {")
+	g.P("\t\treturn nil, err")
+	g.P("\t}")
+	g.P("\tif size > 0 && int64(len(page.Items)) == size {")
+	g.P("\t\tif page.NextToken, err = ", ormPackage.Ident("NextPageToken"), "(sort, last); err != nil {")
+	g.P("\t\t\treturn nil, err")
+	g.P("\t\t}")
+	g.P("\t}")
+	g.P("\treturn page, nil")
+	g.P("}")
+	g.P()
+
+	g.P("// Iterate 流式遍历查询结果，每个文档通过UnmarshalBSON解码为没有脏状态的实体")
+	g.P("// 遍历的实体不登记到工作单元，适合批量处理大量文档；调用方负责Close")
+	g.P("func (r *", repoName, ") Iterate(ctx ", contextPackage.Ident("Context"), ", filter ", ormPackage.Ident("Filter"), ", opts ...*", optionsPackage.Ident("FindOptions"), ") (*", ormPackage.Ident("Iterator"), "[*", structName, "], error) {")
+	g.P("\tif err := filter.Err(); err != nil {")
+	g.P("\t\treturn nil, err")
+	g.P("\t}")
+	g.P("\tcursor, err := r.collection.Find(ctx, ", scopedFilter(g, "filter", softDelete), ", opts...)")
+	g.P("\tif err != nil {")
+	g.P("\t\treturn nil, err")
+	g.P("\t}")
+	g.P("\treturn ", ormPackage.Ident("NewIterator"), "(cursor, New", structName, "), nil")
+	g.P("}")
+	g.P()
+
+	g.P("// Aggregate 执行聚合管道，结果文档通过UnmarshalBSON解码为", structName, "，结果需保持", structName, "的文档结构")
+	g.P("// 分组等改变文档结构的聚合使用orm.Aggregate解码为临时结构体；解码的实体不登记到工作单元")
+	g.P("func (r *", repoName, ") Aggregate(ctx ", contextPackage.Ident("Context"), ", pipeline *", ormPackage.Ident("Pipeline"), ", opts ...*", optionsPackage.Ident("AggregateOptions"), ") (*", ormPackage.Ident("Iterator"), "[*", structName, "], error) {")
+	if softDelete {
+		g.P("\tif scope := r.deleted.Filter(); len(scope.D()) > 0 {")
+		g.P("\t\tpipeline = pipeline.Scoped(scope)")
+		g.P("\t}")
+	}
+	g.P("\treturn ", ormPackage.Ident("AggregateIterator"), "(ctx, r.collection, pipeline, New", structName, ", opts...)")
+	g.P("}")
+	g.P()
+
+	g.P("// Count 统计满足条件的文档数")
+	g.P("func (r *", repoName, ") Count(ctx ", contextPackage.Ident("Context"), ", filter ", ormPackage.Ident("Filter"), ", opts ...*", optionsPackage.Ident("CountOptions"), ") (int64, error) {")
+	g.P("\tif err := filter.Err(); err != nil {")
+	g.P("\t\treturn 0, err")
+	g.P("\t}")
+	g.P("\treturn r.collection.CountDocuments(ctx, ", scopedFilter(g, "filter", softDelete), ", opts...)")
+	g.P("}")
+	g.P()
+
+	g.P("// track 绑定工作单元时返回已跟踪的实例，否则登记新加载的实体")
+	g.P("func (r *", repoName, ") track(x *", structName, ") (*", structName, ", error) {")
+	g.P("\tif r.unitOfWork == nil {")
+	g.P("\t\treturn x, nil")
+	g.P("\t}")
+	g.P("\tif doc, ok := r.unitOfWork.Lookup(r.collection, x.PrimaryKey()); ok {")
+	g.P("\t\tif tracked, ok := doc.(*", structName, "); ok {")
+	g.P("\t\t\treturn tracked, nil")
+	g.P("\t\t}")
+	g.P("\t}")
+	g.P("\treturn x, r.unitOfWork.Register(r.collection, x)")
+	g.P("}")
+	g.P()
+
+	g.P("// Save 将脏字段增量写回数据库，没有变更时不访问数据库")
+	g.P("// 写入前取出脏状态，写入期间的新修改保留到下次保存；写入失败时脏状态合并回实体")
+	if versionField != nil {
+		g.P("// 版本字段参与过滤并自增，版本不一致时返回*orm.VersionConflictError")
+	} else {
+		g.P("// 文档不存在时返回mongo.ErrNoDocuments")
+	}
+	if audit {
+		g.P("// 绑定审计器时由saveAudited在事务中同时写入审计记录")
+	}
+	g.P("// ", structName, "实现了orm.BeforeUpdater时先以脏字段索引调用BeforeUpdate，写入成功后调用AfterUpdate")
+	g.P("func (r *", repoName, ") Save(ctx ", contextPackage.Ident("Context"), ", x *", structName, ") error {")
+	g.P("\tif h, ok := interface{}(x).(", ormPackage.Ident("BeforeUpdater"), "); ok && x.IsDirty() {")
+	g.P("\t\tif err := h.BeforeUpdate(ctx, x.GetDirtyFieldIndexes()); err != nil {")
+	g.P("\t\t\treturn err")
+	g.P("\t\t}")
+	g.P("\t}")
+	if audit {
+		g.P("\tif r.auditor != nil {")
+		g.P("\t\treturn r.saveAudited(ctx, x)")
+		g.P("\t}")
+	}
+	g.P("\tmodel, snapshot, err := x.TakeUpdateModel()")
+	g.P("\tif err != nil || model == nil {")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	generateCaptureConflict(g, message, "\t")
+	g.P("\tresult, err := r.collection.UpdateOne(ctx, model.Filter, model.Update)")
+	g.P("\tif err != nil {")
+	g.P("\t\tx.RestoreSnapshot(snapshot)")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\tif result.MatchedCount == 0 {")
+	g.P("\t\tx.RestoreSnapshot(snapshot)")
+	generateNotMatchedError(g, message, "\t\t")
+	g.P("\t}")
+	g.P("\tx.MarkSaved()")
+	generateHookCall(g, "AfterUpdater", "AfterUpdate(ctx)", "err")
+	g.P("\treturn nil")
+	g.P("}")
+	g.P()
+
+	if audit {
+		g.P("// saveAudited 在一个事务中写入增量更新和本次修改的审计记录，任一失败时脏状态合并回实体")
+		g.P("// 审计器关闭事务时更新已写入而审计记录失败返回orm.ErrAuditWrite，实体按已保存处理")
+		g.P("func (r *", repoName, ") saveAudited(ctx ", contextPackage.Ident("Context"), ", x *", structName, ") error {")
+		g.P("\tmodel, snapshot, changes, err := x.TakeAuditedUpdateModel()")
+		g.P("\tif err != nil || model == nil {")
+		g.P("\t\treturn err")
+		g.P("\t}")
+		generateCaptureConflict(g, message, "\t")
+		g.P("\tentry := &", ormPackage.Ident("AuditEntry"), "{Collection: r.collection.Name(), EntityID: x.PrimaryKey(), Changes: changes}")
+		g.P("\terr = r.auditor.Record(ctx, entry, func(ctx ", contextPackage.Ident("Context"), ") error {")
+		g.P("\t\tresult, err := r.collection.UpdateOne(ctx, model.Filter, model.Update)")
+		g.P("\t\tif err != nil {")
+		g.P("\t\t\treturn err")
+		g.P("\t\t}")
+		g.P("\t\tif result.MatchedCount == 0 {")
+		generateNotMatchedError(g, message, "\t\t\t")
+		g.P("\t\t}")
+		g.P("\t\treturn nil")
+		g.P("\t})")
+		g.P("\tif ", errorsPackage.Ident("Is"), "(err, ", ormPackage.Ident("ErrAuditWrite"), ") {")
+		g.P("\t\t// 更新已经写入，只是审计记录写入失败，不回滚内存中的状态")
+		g.P("\t\tx.MarkSaved()")
+		g.P("\t\treturn err")
+		g.P("\t}")
+		g.P("\tif err != nil {")
+		g.P("\t\tx.RestoreSnapshot(snapshot)")
+		g.P("\t\treturn err")
+		g.P("\t}")
+		g.P("\tx.MarkSaved()")
+		generateHookCall(g, "AfterUpdater", "AfterUpdate(ctx)", "err")
+		g.P("\treturn nil")
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// beforeDelete ", structName, "实现了orm.BeforeDeleter时按主键加载实体并调用BeforeDelete，文档不存在时不调用")
+	g.P("func (r *", repoName, ") beforeDelete(ctx ", contextPackage.Ident("Context"), ", id ", pkType, ") error {")
+	g.P("\tif _, ok := interface{}((*", structName, ")(nil)).(", ormPackage.Ident("BeforeDeleter"), "); !ok {")
+	g.P("\t\treturn nil")
+	g.P("\t}")
+	g.P("\tx, err := r.FindByID(ctx, id)")
+	g.P("\tif ", errorsPackage.Ident("Is"), "(err, ", mongoPackage.Ident("ErrNoDocuments"), ") {")
+	g.P("\t\treturn nil")
+	g.P("\t}")
+	g.P("\tif err != nil {")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\treturn interface{}(x).(", ormPackage.Ident("BeforeDeleter"), ").BeforeDelete(ctx)")
+	g.P("}")
+	g.P()
+
+	if softDelete {
+		generateSoftDelete(g, message, repoName, pkType)
+		return
+	}
+
+	g.P("// Delete 按主键删除文档，", structName, "实现了orm.BeforeDeleter时先加载实体并调用BeforeDelete")
+	g.P("func (r *", repoName, ") Delete(ctx ", contextPackage.Ident("Context"), ", id ", pkType, ") error {")
+	g.P("\tif err := r.beforeDelete(ctx, id); err != nil {")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\tfilter := ", bsonPackage.Ident("D"), "{{Key: \"_id\", Value: id}}")
+	g.P("\tif _, err := r.collection.DeleteOne(ctx, filter); err != nil {")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\tif r.unitOfWork != nil {")
+	g.P("\t\tr.unitOfWork.Evict(r.collection, id)")
+	g.P("\t}")
+	g.P("\treturn nil")
+	g.P("}")
+	g.P()
+}
+
+// generateHookCall 生成实体实现了生命周期钩子接口时调用钩子的代码，钩子返回错误时以ret返回
+func generateHookCall(g *protogen.GeneratedFile, iface, call, ret string) {
+	g.P("\tif h, ok := interface{}(x).(", ormPackage.Ident(iface), "); ok {")
+	g.P("\t\tif err := h.", call, "; err != nil {")
+	g.P("\t\t\treturn ", ret)
+	g.P("\t\t}")
+	g.P("\t}")
+}
+
+// generateCaptureConflict 有版本字段时在写入前构造版本冲突错误，主键和版本号在持锁状态下读取
+func generateCaptureConflict(g *protogen.GeneratedFile, message *protogen.Message, indent string) {
+	if getVersionField(message) != nil {
+		g.P(indent, "conflict := x.versionConflict(r.collection.Name())")
+	}
+}
+
+// generateNotMatchedError 生成增量更新没有匹配到文档时返回的错误，版本冲突错误由generateCaptureConflict构造
+func generateNotMatchedError(g *protogen.GeneratedFile, message *protogen.Message, indent string) {
+	if getVersionField(message) == nil {
+		g.P(indent, "return ", mongoPackage.Ident("ErrNoDocuments"))
+		return
+	}
+	g.P(indent, "return conflict")
+}
+
+// generateIDFilter 生成按主键查询的过滤条件，启用软删除时加上当前的删除范围
+func generateIDFilter(g *protogen.GeneratedFile, softDelete bool) {
+	if softDelete {
+		g.P("\tfilter := append(", bsonPackage.Ident("D"), "{{Key: \"_id\", Value: id}}, r.deleted.Filter().D()...)")
+		return
+	}
+	g.P("\tfilter := ", bsonPackage.Ident("D"), "{{Key: \"_id\", Value: id}}")
+}
+
+// scopedFilter 返回渲染查询条件的表达式，启用软删除时合并当前的删除范围
+func scopedFilter(g *protogen.GeneratedFile, filter string, softDelete bool) string {
+	if softDelete {
+		return g.QualifiedGoIdent(ormPackage.Ident("And")) + "(" + filter + ", r.deleted.Filter()).D()"
+	}
+	return filter + ".D()"
+}
+
+// generateSoftDelete 生成软删除的Delete和Restore，删除和恢复都使版本号自增
+func generateSoftDelete(g *protogen.GeneratedFile, message *protogen.Message, repoName, pkType string) {
+	versionField := getVersionField(message)
+	deletedAt := g.QualifiedGoIdent(ormPackage.Ident("SoftDeleteField"))
+
+	g.P("// Delete 软删除：写入deleted_at时间戳，文档仍保留在集合中，已删除的文档不会重复写入")
+	g.P("// ", message.GoIdent.GoName, "实现了orm.BeforeDeleter时先加载实体并调用BeforeDelete")
+	if versionField != nil {
+		g.P("// 版本号自增，内存中删除前加载的实体保存时返回版本冲突")
+	}
+	g.P("func (r *", repoName, ") Delete(ctx ", contextPackage.Ident("Context"), ", id ", pkType, ") error {")
+	g.P("\tif err := r.beforeDelete(ctx, id); err != nil {")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\tfilter := ", bsonPackage.Ident("D"), "{{Key: \"_id\", Value: id}, {Key: ", deletedAt, ", Value: nil}}")
+	if updateTimeField := getAutoUpdateTimeField(message); updateTimeField != nil {
+		g.P("\tnow := ", timePackage.Ident("Now"), "()")
+		g.P("\tupdate := ", bsonPackage.Ident("M"), "{\"$set\": ", bsonPackage.Ident("M"), "{", deletedAt, ": now, \"", getBsonName(updateTimeField), "\": now.UnixMilli()}}")
+	} else {
+		g.P("\tupdate := ", bsonPackage.Ident("M"), "{\"$set\": ", bsonPackage.Ident("M"), "{", deletedAt, ": ", timePackage.Ident("Now"), "()}}")
+	}
+	if versionField != nil {
+		g.P("\tupdate[\"$inc\"] = ", bsonPackage.Ident("M"), "{\"", getBsonName(versionField), "\": int64(1)}")
+	}
+	g.P("\tif _, err := r.collection.UpdateOne(ctx, filter, update); err != nil {")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\tif r.unitOfWork != nil {")
+	g.P("\t\tr.unitOfWork.Evict(r.collection, id)")
+	g.P("\t}")
+	g.P("\treturn nil")
+	g.P("}")
+	g.P()
+
+	g.P("// Restore 恢复已软删除的文档，文档不存在或未被删除时返回mongo.ErrNoDocuments")
+	g.P("// 恢复会改变文档，绑定工作单元时停止跟踪之前加载的实例，之后通过FindByID重新加载")
+	g.P("func (r *", repoName, ") Restore(ctx ", contextPackage.Ident("Context"), ", id ", pkType, ") error {")
+	g.P("\tfilter := ", bsonPackage.Ident("D"), "{{Key: \"_id\", Value: id}, {Key: ", deletedAt, ", Value: ", bsonPackage.Ident("M"), "{\"$ne\": nil}}}")
+	g.P("\tupdate := ", bsonPackage.Ident("M"), "{\"$unset\": ", bsonPackage.Ident("M"), "{", deletedAt, ": \"\"}}")
+	if updateTimeField := getAutoUpdateTimeField(message); updateTimeField != nil {
+		g.P("\tupdate[\"$set\"] = ", bsonPackage.Ident("M"), "{\"", getBsonName(updateTimeField), "\": ", timePackage.Ident("Now"), "().UnixMilli()}")
+	}
+	if versionField != nil {
+		g.P("\tupdate[\"$inc\"] = ", bsonPackage.Ident("M"), "{\"", getBsonName(versionField), "\": int64(1)}")
+	}
+	g.P("\tresult, err := r.collection.UpdateOne(ctx, filter, update)")
+	g.P("\tif err != nil {")
+	g.P("\t\treturn err")
+	g.P("\t}")
+	g.P("\tif result.MatchedCount == 0 {")
+	g.P("\t\treturn ", mongoPackage.Ident("ErrNoDocuments"))
+	g.P("\t}")
+	g.P("\tif r.unitOfWork != nil {")
+	g.P("\t\tr.unitOfWork.Evict(r.collection, id)")
+	g.P("\t}")
+	g.P("\treturn nil")
+	g.P("}")
+	g.P()
+
+}
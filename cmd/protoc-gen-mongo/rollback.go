@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// trackOriginal rollback和audit模式都需要记录字段原值
+func trackOriginal() bool {
+	return rollback || audit
+}
+
+// originalTypeName 返回记录字段原值的结构体名
+func originalTypeName(structName string) string {
+	return strings.ToLower(structName[:1]) + structName[1:] + "Original"
+}
+
+// generateOriginalStruct 生成记录字段原值的结构体，仅在rollback或audit模式生成
+func generateOriginalStruct(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	if !trackOriginal() {
+		return
+	}
+	g.P("// ", originalTypeName(structName), " 记录", structName, "各字段首次修改前的值")
+	g.P("type ", originalTypeName(structName), " struct {")
+	for _, field := range message.Fields {
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		g.P("\t", fieldName, " ", getGoType(field))
+	}
+	g.P("}")
+	g.P()
+}
+
+// generateOriginalDirtyFields 在脏标记结构体中生成原值记录字段
+func generateOriginalDirtyFields(g *protogen.GeneratedFile, structName string, bitmapSize int) {
+	if !trackOriginal() {
+		return
+	}
+	g.P("\t// 已记录原值的字段位图，Rollback只恢复这些字段")
+	if bitmapSize == 1 {
+		g.P("\tOriginalBitmap uint64")
+	} else {
+		g.P("\tOriginalBitmap [", bitmapSize, "]uint64")
+	}
+	g.P("\toriginal ", originalTypeName(structName), " // 字段首次修改前的值")
+}
+
+// generateCaptureOriginal 在修改字段前记录原值，仅在rollback或audit模式生成
+func generateCaptureOriginal(g *protogen.GeneratedFile, indent, constName string) {
+	if !trackOriginal() {
+		return
+	}
+	g.P(indent, "x.captureOriginal(", constName, ")")
+}
+
+// generateRollbackMethods 生成captureOriginal和Rollback方法，audit模式只生成captureOriginal
+func generateRollbackMethods(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	if !trackOriginal() {
+		return
+	}
+	bitmapSize := (len(message.Fields) + 63) / 64
+	generateBitmapTest(g, structName, "isOriginalCaptured", "OriginalBitmap", "检查指定字段是否已记录原值", bitmapSize)
+	generateBitmapSet(g, structName, "setOriginalCaptured", "OriginalBitmap", "标记指定字段已记录原值", bitmapSize)
+
+	g.P("// captureOriginal 字段在上次ResetDirty之后首次修改时记录原值")
+	g.P("// 数组和字典会被原地修改，记录副本；嵌套消息记录原对象，其内部修改由子对象自己记录")
+	g.P("func (x *", structName, ") captureOriginal(fieldIndex int) {")
+	g.P("\tif x.Dirty == nil || x.isOriginalCaptured(fieldIndex) {")
+	g.P("\t\treturn")
+	g.P("\t}")
+	g.P("\tx.setOriginalCaptured(fieldIndex)")
+	g.P("\tswitch fieldIndex {")
+	for _, field := range message.Fields {
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+		g.P("\tcase ", constName, ":")
+		if isArrayOrMap(field) {
+			g.P("\t\tx.Dirty.original.", fieldName, " = ", cloneValueExpr(g, field, "x."+fieldName))
+		} else {
+			g.P("\t\tx.Dirty.original.", fieldName, " = x.", fieldName)
+		}
+	}
+	g.P("\t}")
+	g.P("}")
+	g.P()
+
+	if !rollback {
+		return
+	}
+	g.P("// Rollback 撤销上次ResetDirty（加载或保存）之后的内存修改，恢复字段原值并清除脏标记")
+	g.P("// 递归撤销嵌套消息内部的修改；恢复时不通知父对象和字段监听器")
+	if trackUpgraded(message) {
+		g.P("// 加载时升级改变的字段没有原值可恢复，保持标脏，下次保存时与_v一起写回")
+	}
+	generateLockedMethod(g, structName, "Rollback", "", "", "", true, func() {
+		g.P("\tif x == nil || x.Dirty == nil {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		for _, field := range message.Fields {
+			fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+			constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+			g.P("\tif x.isOriginalCaptured(", constName, ") {")
+			g.P("\t\tx.", fieldName, " = x.Dirty.original.", fieldName)
+			if isMessageField(field) {
+				g.P("\t\tif x.", fieldName, " != nil {")
+				g.P("\t\t\tx.", fieldName, ".", internalName("SetParentNotifier"), "(x, ", constName, ")")
+				g.P("\t\t}")
+			}
+			g.P("\t}")
+			if isMessageField(field) {
+				g.P("\tx.", fieldName, ".", internalName("Rollback"), "()")
+			}
+		}
+		generateUpgradedRollback(g, message, true)
+		g.P("\tx.", internalName("ResetDirty"), "()")
+		generateUpgradedRollback(g, message, false)
+	})
+}
+
+// generateOriginalReset 生成ResetDirty中清除原值记录的代码，释放对旧值的引用
+func generateOriginalReset(g *protogen.GeneratedFile, structName string, bitmapSize int) {
+	if !trackOriginal() {
+		return
+	}
+	if bitmapSize == 1 {
+		g.P("\tx.Dirty.OriginalBitmap = 0")
+	} else {
+		g.P("\tx.Dirty.OriginalBitmap = [", bitmapSize, "]uint64{}")
+	}
+	g.P("\tx.Dirty.original = ", originalTypeName(structName), "{}")
+}
+
+// generateOriginalMerge 生成RestoreDirty中合并原值记录的代码
+// 快照中的原值早于当前记录，两边都记录了的字段以快照为准
+func generateOriginalMerge(g *protogen.GeneratedFile, message *protogen.Message, bitmapSize int) {
+	if !trackOriginal() {
+		return
+	}
+	for i, field := range message.Fields {
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		bitmap := "s.dirty.OriginalBitmap"
+		if bitmapSize > 1 {
+			bitmap = fmt.Sprintf("s.dirty.OriginalBitmap[%d]", i/64)
+		}
+		g.P("\tif ", bitmap, "&(1<<", i%64, ") != 0 {")
+		g.P("\t\tx.Dirty.original.", fieldName, " = s.dirty.original.", fieldName)
+		g.P("\t}")
+	}
+	if bitmapSize == 1 {
+		g.P("\tx.Dirty.OriginalBitmap |= s.dirty.OriginalBitmap")
+	} else {
+		g.P("\tfor i := range x.Dirty.OriginalBitmap {")
+		g.P("\t\tx.Dirty.OriginalBitmap[i] |= s.dirty.OriginalBitmap[i]")
+		g.P("\t}")
+	}
+}
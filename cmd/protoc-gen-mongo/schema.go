@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"DB/orm/ormpb"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// formatPatterns MongoDB的$jsonSchema不支持format关键字，格式规则以正则近似
+var formatPatterns = map[string]string{
+	"email": `^[^@\s]+@[^@\s]+$`,
+	"uuid":  `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`,
+}
+
+// schemaDoc 按顺序收集schema的键和生成代码中的值表达式
+type schemaDoc []string
+
+func (d *schemaDoc) add(key, value string) {
+	*d = append(*d, "{Key: "+strconv.Quote(key)+", Value: "+value+"}")
+}
+
+// expr 返回构造该schema的bson.D字面量
+func (d schemaDoc) expr(g *protogen.GeneratedFile) string {
+	return g.QualifiedGoIdent(bsonPackage.Ident("D")) + "{" + strings.Join(d, ", ") + "}"
+}
+
+// bsonArray 返回bson.A字面量
+func bsonArray(g *protogen.GeneratedFile, items []string) string {
+	return g.QualifiedGoIdent(bsonPackage.Ident("A")) + "{" + strings.Join(items, ", ") + "}"
+}
+
+// bsonTypeName 返回字段（数组为元素）按Go类型编码后的BSON类型名，不支持的类型返回空
+// 无符号整数由驱动编码为int64
+func bsonTypeName(field *protogen.Field) string {
+	switch getFieldOptions(field).GetIdType() {
+	case ormpb.IdType_OBJECT_ID:
+		return "objectId"
+	case ormpb.IdType_UUID:
+		return "binData"
+	}
+	if isDecimalField(field) {
+		return "decimal"
+	}
+	switch field.Desc.Kind() {
+	case protoreflect.StringKind:
+		return "string"
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return "int"
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return "long"
+	case protoreflect.BoolKind:
+		return "bool"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return "double"
+	case protoreflect.BytesKind:
+		return "binData"
+	case protoreflect.MessageKind:
+		return "object"
+	}
+	return ""
+}
+
+// referencedMessage 返回字段引用的消息，字典为值的消息，不引用消息时返回nil
+func referencedMessage(field *protogen.Field) *protogen.Message {
+	if field.Desc.IsMap() {
+		field = field.Message.Fields[1]
+	}
+	if field.Desc.Kind() != protoreflect.MessageKind {
+		return nil
+	}
+	return field.Message
+}
+
+// reachesMessage 判断字段引用的消息是否经由任意消息字段引用回message
+func reachesMessage(message *protogen.Message, field *protogen.Field) bool {
+	visited := map[*protogen.Message]bool{}
+	var reaches func(m *protogen.Message) bool
+	reaches = func(m *protogen.Message) bool {
+		if m == nil || visited[m] {
+			return false
+		}
+		if m == message {
+			return true
+		}
+		visited[m] = true
+		for _, f := range m.Fields {
+			if reaches(referencedMessage(f)) {
+				return true
+			}
+		}
+		return false
+	}
+	return reaches(referencedMessage(field))
+}
+
+// generateJSONSchema 生成<Message>JSONSchema，由字段类型和校验规则推导$jsonSchema
+func generateJSONSchema(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	var required []string
+	for _, field := range message.Fields {
+		if getFieldRules(field).GetRequired() {
+			required = append(required, strconv.Quote(getBsonName(field)))
+		}
+	}
+
+	g.P("// ", structName, "JSONSchema 返回", structName, "文档的$jsonSchema，由字段类型和校验规则推导")
+	g.P("// 嵌套消息引用其JSONSchema，format规则以正则近似，字符串为空时与Validate一致只检查required")
+	g.P("func ", structName, "JSONSchema() ", bsonPackage.Ident("D"), " {")
+	g.P("\treturn ", bsonPackage.Ident("D"), "{")
+	g.P("\t\t{Key: \"bsonType\", Value: \"object\"},")
+	if len(required) > 0 {
+		g.P("\t\t{Key: \"required\", Value: ", bsonArray(g, required), "},")
+	}
+	g.P("\t\t{Key: \"properties\", Value: ", bsonPackage.Ident("D"), "{")
+	for _, field := range message.Fields {
+		g.P("\t\t\t{Key: ", strconv.Quote(getBsonName(field)), ", Value: ", fieldSchema(g, message, field), "},")
+	}
+	if getMessageOptions(message).GetSchemaVersion() > 0 {
+		var version schemaDoc
+		version.add("bsonType", `"int"`)
+		g.P("\t\t\t{Key: ", ormPackage.Ident("SchemaVersionField"), ", Value: ", version.expr(g), "},")
+	}
+	if getMessageOptions(message).GetSoftDelete() {
+		var deletedAt schemaDoc
+		deletedAt.add("bsonType", bsonArray(g, []string{`"date"`, `"null"`}))
+		g.P("\t\t\t{Key: ", ormPackage.Ident("SoftDeleteField"), ", Value: ", deletedAt.expr(g), "},")
+	}
+	g.P("\t\t}},")
+	g.P("\t}")
+	g.P("}")
+	g.P()
+}
+
+// fieldSchema 返回字段schema的表达式，数组和字典的取值规则作用于元素
+func fieldSchema(g *protogen.GeneratedFile, message *protogen.Message, field *protogen.Field) string {
+	rules := getFieldRules(field)
+	if rules == nil {
+		rules = &ormpb.FieldRules{}
+	}
+	var doc schemaDoc
+	switch {
+	case field.Desc.IsMap():
+		doc.add("bsonType", `"object"`)
+		addItemLimits(&doc, rules, "minProperties", "maxProperties")
+		doc.add("additionalProperties", valueSchema(g, message, field.Message.Fields[1], &ormpb.FieldRules{}, false))
+	case field.Desc.IsList():
+		doc.add("bsonType", `"array"`)
+		addItemLimits(&doc, rules, "minItems", "maxItems")
+		doc.add("items", valueSchema(g, message, field, rules, false))
+	default:
+		return valueSchema(g, message, field, rules, rules.GetRequired())
+	}
+	return doc.expr(g)
+}
+
+// addItemLimits 添加元素个数限制，required的数组和字典至少有一个元素
+func addItemLimits(doc *schemaDoc, rules *ormpb.FieldRules, minKey, maxKey string) {
+	minItems := rules.GetMinItems()
+	if rules.GetRequired() && minItems < 1 {
+		minItems = 1
+	}
+	if minItems > 0 {
+		doc.add(minKey, fmt.Sprint(minItems))
+	}
+	if rules.MaxItems != nil {
+		doc.add(maxKey, fmt.Sprint(rules.GetMaxItems()))
+	}
+}
+
+// valueSchema 返回单个取值的schema表达式，未设置的嵌套消息写为null
+func valueSchema(g *protogen.GeneratedFile, message *protogen.Message, field *protogen.Field, rules *ormpb.FieldRules, required bool) string {
+	var doc schemaDoc
+	if field.Desc.Kind() == protoreflect.MessageKind {
+		var schema string
+		if reachesMessage(message, field) {
+			// 递归引用的消息不展开，只限定类型
+			doc.add("bsonType", `"object"`)
+			schema = doc.expr(g)
+		} else {
+			schema = g.QualifiedGoIdent(field.Message.GoIdent.GoImportPath.Ident(field.Message.GoIdent.GoName+"JSONSchema")) + "()"
+		}
+		if required {
+			return schema
+		}
+		var null schemaDoc
+		null.add("bsonType", `"null"`)
+		var nullable schemaDoc
+		nullable.add("anyOf", bsonArray(g, []string{null.expr(g), schema}))
+		return nullable.expr(g)
+	}
+
+	if t := bsonTypeName(field); t != "" {
+		doc.add("bsonType", strconv.Quote(t))
+	}
+	if ident, ok := idTypeIdent(field); ok {
+		if required {
+			var zero schemaDoc
+			zero.add("enum", bsonArray(g, []string{g.QualifiedGoIdent(ident) + "{}"}))
+			doc.add("not", zero.expr(g))
+		}
+		return doc.expr(g)
+	}
+	switch {
+	case isNumericKind(field) || isDecimalField(field):
+		if rules.Min != nil {
+			doc.add("minimum", strconv.FormatFloat(rules.GetMin(), 'g', -1, 64))
+		}
+		if rules.Max != nil {
+			doc.add("maximum", strconv.FormatFloat(rules.GetMax(), 'g', -1, 64))
+		}
+		if len(rules.GetIn()) > 0 {
+			literals, _ := numericInValues(field, rules.GetIn())
+			doc.add("enum", bsonArray(g, literals))
+		}
+		if required {
+			var zero schemaDoc
+			zero.add("enum", bsonArray(g, []string{"0"}))
+			doc.add("not", zero.expr(g))
+		}
+	case field.Desc.Kind() == protoreflect.StringKind:
+		// 长度和取值范围对空字符串同样检查，与Validate一致；pattern和format在非空时检查
+		minLen := rules.GetMinLen()
+		if required && minLen < 1 {
+			minLen = 1
+		}
+		if minLen > 0 {
+			doc.add("minLength", fmt.Sprint(minLen))
+		}
+		if rules.MaxLen != nil {
+			doc.add("maxLength", fmt.Sprint(rules.GetMaxLen()))
+		}
+		if len(rules.GetIn()) > 0 {
+			seen := map[string]bool{}
+			var literals []string
+			for _, item := range rules.GetIn() {
+				if !seen[item] {
+					seen[item] = true
+					literals = append(literals, strconv.Quote(item))
+				}
+			}
+			doc.add("enum", bsonArray(g, literals))
+		}
+		var checks schemaDoc
+		var patterns []string
+		if rules.GetPattern() != "" {
+			patterns = append(patterns, rules.GetPattern())
+		}
+		if rules.GetFormat() != "" {
+			patterns = append(patterns, formatPatterns[rules.GetFormat()])
+		}
+		if len(patterns) > 0 {
+			checks.add("pattern", strconv.Quote(patterns[0]))
+		}
+		if len(patterns) > 1 {
+			var format schemaDoc
+			format.add("pattern", strconv.Quote(patterns[1]))
+			checks.add("allOf", bsonArray(g, []string{format.expr(g)}))
+		}
+		switch {
+		case len(checks) == 0:
+		case required:
+			doc = append(doc, checks...)
+		default:
+			var empty schemaDoc
+			empty.add("enum", bsonArray(g, []string{`""`}))
+			doc.add("anyOf", bsonArray(g, []string{empty.expr(g), checks.expr(g)}))
+		}
+	}
+	return doc.expr(g)
+}
+
+// generateSchemaValidator 为声明了collection的消息生成集合名常量和Apply<Message>SchemaValidator
+func generateSchemaValidator(g *protogen.GeneratedFile, message *protogen.Message) {
+	collection := getMessageOptions(message).GetCollection()
+	if collection == "" {
+		return
+	}
+	structName := message.GoIdent.GoName
+	g.P("// ", structName, "CollectionName ", structName, "所在的集合名")
+	g.P("const ", structName, "CollectionName = ", strconv.Quote(collection))
+	g.P()
+	g.P("// Apply", structName, "SchemaValidator 把", structName, "JSONSchema作为校验器应用到db中的", collection, "集合，集合不存在时创建")
+	g.P("func Apply", structName, "SchemaValidator(ctx ", contextPackage.Ident("Context"), ", db *", mongoPackage.Ident("Database"), ") error {")
+	g.P("\treturn ", ormPackage.Ident("ApplySchemaValidator"), "(ctx, db, ", structName, "CollectionName, ", structName, "JSONSchema())")
+	g.P("}")
+	g.P()
+}
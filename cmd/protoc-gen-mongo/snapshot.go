@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generateSnapshotMethods 生成脏状态快照类型以及TakeDirty/RestoreDirty方法
+// 异步保存时先取出脏状态再写入，写入期间的新修改记录在新的脏状态中，失败时把快照合并回去
+func generateSnapshotMethods(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	fieldCount := len(message.Fields)
+	bitmapSize := (fieldCount + 63) / 64
+	snapshotName := structName + "DirtySnapshot"
+
+	g.P("// ", snapshotName, " TakeDirty取出的脏状态快照")
+	g.P("type ", snapshotName, " struct {")
+	g.P("\tdirty *", structName, "Dirty")
+	for _, field := range message.Fields {
+		if isMessageField(field) {
+			fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+			g.P("\t", fieldName, " *", field.Message.GoIdent.GoName, "DirtySnapshot // 嵌套消息的脏状态")
+		}
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// IsEmpty 快照中是否没有任何变更")
+	g.P("func (s *", snapshotName, ") IsEmpty() bool {")
+	if getMessageOptions(message).GetSchemaVersion() > 0 {
+		// 只有升级记录的快照仍需写入_v
+		g.P("\treturn s == nil || s.dirty == nil || s.dirty.TotalChanges == 0 && !s.dirty.SchemaUpgraded")
+	} else {
+		g.P("\treturn s == nil || s.dirty == nil || s.dirty.TotalChanges == 0")
+	}
+	g.P("}")
+	g.P()
+
+	g.P("// TakeDirty 取出当前脏状态（位图、元素集合以及嵌套消息的脏状态）并清除")
+	g.P("// 取出后对象变为干净状态，之后的修改记录在新的脏状态中，不会被本次保存的结果清除")
+	generateLockedMethod(g, structName, "TakeDirty", "", "", "*"+snapshotName, true, func() {
+		g.P("\tif x == nil || x.Dirty == nil {")
+		g.P("\t\treturn nil")
+		g.P("\t}")
+		g.P("\tsnapshot := &", snapshotName, "{dirty: x.Dirty}")
+		for _, field := range message.Fields {
+			if isMessageField(field) {
+				fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+				g.P("\tsnapshot.", fieldName, " = x.", fieldName, ".", internalName("TakeDirty"), "()")
+			}
+		}
+		g.P("\tx.Dirty = nil")
+		g.P("\tx.", internalName("EnsureDirty"), "()")
+		g.P("\treturn snapshot")
+	})
+
+	g.P("// RestoreDirty 将TakeDirty取出的快照合并回当前脏状态，用于写入失败后重试")
+	generateLockedMethod(g, structName, "RestoreDirty", "s *"+snapshotName, "s", "", true, func() {
+		g.P("\tif x == nil || s == nil || s.dirty == nil {")
+		g.P("\t\treturn")
+		g.P("\t}")
+		g.P("\tx.", internalName("EnsureDirty"), "()")
+		generateIncrementMerge(g, message, bitmapSize)
+		generateUpgradedMerge(g, message)
+		if bitmapSize == 1 {
+			g.P("\tx.Dirty.FieldsBitmap |= s.dirty.FieldsBitmap")
+			g.P("\tx.Dirty.ReplacedBitmap |= s.dirty.ReplacedBitmap")
+		} else {
+			g.P("\tfor i := range x.Dirty.FieldsBitmap {")
+			g.P("\t\tx.Dirty.FieldsBitmap[i] |= s.dirty.FieldsBitmap[i]")
+			g.P("\t\tx.Dirty.ReplacedBitmap[i] |= s.dirty.ReplacedBitmap[i]")
+			g.P("\t}")
+		}
+		generateOriginalMerge(g, message, bitmapSize)
+		for _, field := range message.Fields {
+			if isArrayOrMap(field) {
+				publicFieldName := strings.Title(strings.ToLower(field.GoName[:1]) + field.GoName[1:])
+				g.P("\tfor key := range s.dirty.", publicFieldName, "Elements {")
+				g.P("\t\tx.Dirty.", publicFieldName, "Elements[key] = true")
+				g.P("\t}")
+			}
+		}
+		for _, field := range message.Fields {
+			if isMessageField(field) {
+				fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+				constName := fmt.Sprintf("%s%sFieldIndex", structName, field.GoName)
+				g.P("\t// 嵌套消息已被整体替换时由父对象写入整个值，不需要合并子对象的脏状态")
+				g.P("\tif !x.isFieldReplaced(", constName, ") {")
+				g.P("\t\tx.", fieldName, ".", internalName("RestoreDirty"), "(s.", fieldName, ")")
+				g.P("\t}")
+			}
+		}
+		g.P("\tx.Dirty.TotalChanges = len(x.", internalName("GetDirtyFieldIndexes"), "())")
+		g.P("\tif x.Dirty.TotalChanges > 0 {")
+		g.P("\t\tx.notifyParentDirty()")
+		g.P("\t}")
+	})
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"DB/orm/ormpb"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// getFieldRules 返回字段的校验规则，未声明时返回nil
+func getFieldRules(field *protogen.Field) *ormpb.FieldRules {
+	return getFieldOptions(field).GetRules()
+}
+
+// isNumericKind 判断字段（数组为元素）是否为数值类型
+func isNumericKind(field *protogen.Field) bool {
+	switch field.Desc.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind,
+		protoreflect.FloatKind, protoreflect.DoubleKind:
+		return true
+	}
+	return false
+}
+
+// checkFieldRules 校验规则是否适用于字段类型
+func checkFieldRules(field *protogen.Field) error {
+	rules := getFieldRules(field)
+	if rules == nil {
+		return nil
+	}
+	name := field.Desc.FullName()
+	isString := !field.Desc.IsMap() && field.Desc.Kind() == protoreflect.StringKind && !hasCustomType(field)
+	isNumeric := !field.Desc.IsMap() && isNumericKind(field)
+	if rules.GetRequired() && !isArrayOrMap(field) && field.Desc.Kind() == protoreflect.BoolKind {
+		return fmt.Errorf("%s: required is not supported on bool fields", name)
+	}
+	if (rules.Min != nil || rules.Max != nil) && !isNumeric && !isDecimalField(field) {
+		return fmt.Errorf("%s: min/max require a numeric field", name)
+	}
+	if (rules.MinLen != nil || rules.MaxLen != nil || rules.GetPattern() != "" || rules.GetFormat() != "") && !isString {
+		return fmt.Errorf("%s: min_len/max_len/pattern/format require a string field", name)
+	}
+	if len(rules.GetIn()) > 0 && !isString && !isNumeric {
+		return fmt.Errorf("%s: in requires a string or numeric field", name)
+	}
+	if (rules.MinItems != nil || rules.MaxItems != nil) && !isArrayOrMap(field) {
+		return fmt.Errorf("%s: min_items/max_items require a repeated or map field", name)
+	}
+	if _, err := regexp.Compile(rules.GetPattern()); err != nil {
+		return fmt.Errorf("%s: invalid pattern: %v", name, err)
+	}
+	switch rules.GetFormat() {
+	case "", "email", "uuid":
+	default:
+		return fmt.Errorf("%s: unknown format %q", name, rules.GetFormat())
+	}
+	if isNumeric {
+		if _, err := numericInValues(field, rules.GetIn()); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// numericInValues 把in中的取值解析为字段类型的字面量并去重
+func numericInValues(field *protogen.Field, values []string) ([]string, error) {
+	seen := map[string]bool{}
+	var literals []string
+	for _, value := range values {
+		var literal string
+		switch getElementType(field) {
+		case "float32", "float64":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid in value %q", value)
+			}
+			literal = strconv.FormatFloat(f, 'g', -1, 64)
+		case "uint32", "uint64":
+			u, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid in value %q", value)
+			}
+			literal = strconv.FormatUint(u, 10)
+		default:
+			i, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid in value %q", value)
+			}
+			literal = strconv.FormatInt(i, 10)
+		}
+		if !seen[literal] {
+			seen[literal] = true
+			literals = append(literals, literal)
+		}
+	}
+	return literals, nil
+}
+
+// validatorName 返回字段校验函数名
+func validatorName(structName string, field *protogen.Field) string {
+	return "validate" + structName + field.GoName
+}
+
+// patternVarName 返回字段正则表达式变量名
+func patternVarName(structName string, field *protogen.Field) string {
+	return strings.ToLower(structName[:1]) + structName[1:] + field.GoName + "Pattern"
+}
+
+// generateValidateMethods 生成字段校验函数、Validate和TrySet<Field>
+func generateValidateMethods(g *protogen.GeneratedFile, message *protogen.Message, structName string) {
+	violation := g.QualifiedGoIdent(ormPackage.Ident("Violation"))
+
+	for _, field := range message.Fields {
+		rules := getFieldRules(field)
+		if rules == nil {
+			continue
+		}
+		if rules.GetPattern() != "" {
+			g.P("var ", patternVarName(structName, field), " = ", regexpPackage.Ident("MustCompile"), "(", strconv.Quote(rules.GetPattern()), ")")
+			g.P()
+		}
+		g.P("// ", validatorName(structName, field), " 按校验规则检查", field.GoName, "的值，违反的规则追加到violations")
+		g.P("func ", validatorName(structName, field), "(path string, v ", getGoType(field), ", violations []", violation, ") []", violation, " {")
+		generateFieldRuleChecks(g, structName, field, rules)
+		g.P("\treturn violations")
+		g.P("}")
+		g.P()
+	}
+
+	g.P("// collectViolations 按校验规则检查所有字段，嵌套消息递归检查，调用方需持有聚合根的锁")
+	g.P("func (x *", structName, ") collectViolations(prefix string, violations []", violation, ") []", violation, " {")
+	g.P("\tif x == nil {")
+	g.P("\t\treturn violations")
+	g.P("\t}")
+	for _, field := range message.Fields {
+		fieldName := strings.ToLower(field.GoName[:1]) + field.GoName[1:]
+		bsonName := getBsonName(field)
+		if getFieldRules(field) != nil {
+			g.P("\tviolations = ", validatorName(structName, field), "(prefix+\"", bsonName, "\", x.", fieldName, ", violations)")
+		}
+		if isMessageField(field) {
+			g.P("\tviolations = x.", fieldName, ".collectViolations(prefix+\"", bsonName, ".\", violations)")
+		}
+	}
+	g.P("\treturn violations")
+	g.P("}")
+	g.P()
+
+	g.P("// Validate 按proto中声明的校验规则检查所有字段，违反规则时返回包含全部违规项的*orm.ValidationError")
+	g.P("func (x *", structName, ") Validate() error {")
+	generateLock(g, false)
+	g.P("\treturn ", ormPackage.Ident("NewValidationError"), "(x.collectViolations(\"\", nil))")
+	g.P("}")
+	g.P()
+
+	for _, field := range message.Fields {
+		if getFieldRules(field) == nil {
+			continue
+		}
+		g.P("// TrySet", field.GoName, " 按校验规则检查后设置", field.GoName, "，违反规则时返回*orm.ValidationError且不修改字段")
+		if isMessageField(field) {
+			g.P("// 只检查字段本身的规则，嵌套消息内部的规则由Validate检查")
+		}
+		g.P("func (x *", structName, ") TrySet", field.GoName, "(v ", getGoType(field), ") error {")
+		g.P("\tif err := ", ormPackage.Ident("NewValidationError"), "(", validatorName(structName, field), "(\"", getBsonName(field), "\", v, nil)); err != nil {")
+		g.P("\t\treturn err")
+		g.P("\t}")
+		g.P("\tx.Set", field.GoName, "(v)")
+		g.P("\treturn nil")
+		g.P("}")
+		g.P()
+	}
+}
+
+// generateFieldRuleChecks 生成校验函数体，数组的取值规则对每个元素检查
+func generateFieldRuleChecks(g *protogen.GeneratedFile, structName string, field *protogen.Field, rules *ormpb.FieldRules) {
+	if rules.GetRequired() {
+		var empty string
+		switch {
+		case hasCustomType(field):
+			empty = "v.IsZero()"
+		case isArrayOrMap(field) || field.Desc.Kind() == protoreflect.BytesKind:
+			empty = "len(v) == 0"
+		case isMessageField(field):
+			empty = "v == nil"
+		case field.Desc.Kind() == protoreflect.StringKind:
+			empty = "v == \"\""
+		default:
+			empty = "v == 0"
+		}
+		g.P("\tif ", empty, " {")
+		generateViolation(g, "\t\t", "path", "required", "is required")
+		g.P("\t}")
+	}
+	if rules.MinItems != nil {
+		g.P("\tif len(v) < ", rules.GetMinItems(), " {")
+		generateViolation(g, "\t\t", "path", "min_items", fmt.Sprintf("must have at least %d items", rules.GetMinItems()))
+		g.P("\t}")
+	}
+	if rules.MaxItems != nil {
+		g.P("\tif len(v) > ", rules.GetMaxItems(), " {")
+		generateViolation(g, "\t\t", "path", "max_items", fmt.Sprintf("must have at most %d items", rules.GetMaxItems()))
+		g.P("\t}")
+	}
+	if field.Desc.IsMap() {
+		return
+	}
+	if field.Desc.IsList() {
+		if !hasValueRules(rules) {
+			return
+		}
+		g.P("\tfor i, item := range v {")
+		g.P("\t\titemPath := path + \".\" + ", strconvPackage.Ident("Itoa"), "(i)")
+		generateValueChecks(g, structName, field, rules, "\t\t", "item", "itemPath")
+		g.P("\t}")
+		return
+	}
+	generateValueChecks(g, structName, field, rules, "\t", "v", "path")
+}
+
+// hasValueRules 判断是否声明了作用于取值的规则
+func hasValueRules(rules *ormpb.FieldRules) bool {
+	return rules.Min != nil || rules.Max != nil || rules.MinLen != nil || rules.MaxLen != nil ||
+		rules.GetPattern() != "" || len(rules.GetIn()) > 0 || rules.GetFormat() != ""
+}
+
+// generateValueChecks 生成对单个取值的检查，字符串为空时跳过pattern和format
+func generateValueChecks(g *protogen.GeneratedFile, structName string, field *protogen.Field, rules *ormpb.FieldRules, indent, value, path string) {
+	if isDecimalField(field) {
+		// 界限按最短的十进制表示比较，不经过浮点数
+		if rules.Min != nil {
+			limit := strconv.FormatFloat(rules.GetMin(), 'g', -1, 64)
+			g.P(indent, "if ", value, ".Cmp(", ormPackage.Ident("MustParseDecimal"), "(\"", limit, "\")) < 0 {")
+			generateViolation(g, indent+"\t", path, "min", "must be >= "+limit)
+			g.P(indent, "}")
+		}
+		if rules.Max != nil {
+			limit := strconv.FormatFloat(rules.GetMax(), 'g', -1, 64)
+			g.P(indent, "if ", value, ".Cmp(", ormPackage.Ident("MustParseDecimal"), "(\"", limit, "\")) > 0 {")
+			generateViolation(g, indent+"\t", path, "max", "must be <= "+limit)
+			g.P(indent, "}")
+		}
+		return
+	}
+	if isNumericKind(field) {
+		if rules.Min != nil {
+			limit := strconv.FormatFloat(rules.GetMin(), 'g', -1, 64)
+			g.P(indent, "if float64(", value, ") < ", limit, " {")
+			generateViolation(g, indent+"\t", path, "min", "must be >= "+limit)
+			g.P(indent, "}")
+		}
+		if rules.Max != nil {
+			limit := strconv.FormatFloat(rules.GetMax(), 'g', -1, 64)
+			g.P(indent, "if float64(", value, ") > ", limit, " {")
+			generateViolation(g, indent+"\t", path, "max", "must be <= "+limit)
+			g.P(indent, "}")
+		}
+		if len(rules.GetIn()) > 0 {
+			literals, _ := numericInValues(field, rules.GetIn())
+			g.P(indent, "switch ", value, " {")
+			g.P(indent, "case ", strings.Join(literals, ", "), ":")
+			g.P(indent, "default:")
+			generateViolation(g, indent+"\t", path, "in", "must be one of ["+strings.Join(literals, " ")+"]")
+			g.P(indent, "}")
+		}
+		return
+	}
+
+	if field.Desc.Kind() != protoreflect.StringKind || !hasValueRules(rules) {
+		return
+	}
+	// 长度和取值范围对空字符串同样检查，pattern和format只在非空时检查
+	if rules.MinLen != nil || rules.MaxLen != nil {
+		g.P(indent, "switch n := ", utf8Package.Ident("RuneCountInString"), "(", value, "); {")
+		if rules.MinLen != nil {
+			g.P(indent, "case n < ", rules.GetMinLen(), ":")
+			generateViolation(g, indent+"\t", path, "min_len", fmt.Sprintf("length must be >= %d", rules.GetMinLen()))
+		}
+		if rules.MaxLen != nil {
+			g.P(indent, "case n > ", rules.GetMaxLen(), ":")
+			generateViolation(g, indent+"\t", path, "max_len", fmt.Sprintf("length must be <= %d", rules.GetMaxLen()))
+		}
+		g.P(indent, "}")
+	}
+	if len(rules.GetIn()) > 0 {
+		seen := map[string]bool{}
+		var literals []string
+		for _, item := range rules.GetIn() {
+			if !seen[item] {
+				seen[item] = true
+				literals = append(literals, strconv.Quote(item))
+			}
+		}
+		g.P(indent, "switch ", value, " {")
+		g.P(indent, "case ", strings.Join(literals, ", "), ":")
+		g.P(indent, "default:")
+		generateViolation(g, indent+"\t", path, "in", "must be one of ["+strings.Join(rules.GetIn(), " ")+"]")
+		g.P(indent, "}")
+	}
+	if rules.GetPattern() == "" && rules.GetFormat() == "" {
+		return
+	}
+	g.P(indent, "if ", value, " != \"\" {")
+	inner := indent + "\t"
+	if rules.GetPattern() != "" {
+		g.P(inner, "if !", patternVarName(structName, field), ".MatchString(", value, ") {")
+		generateViolation(g, inner+"\t", path, "pattern", "must match "+rules.GetPattern())
+		g.P(inner, "}")
+	}
+	switch rules.GetFormat() {
+	case "email":
+		g.P(inner, "if !", ormPackage.Ident("IsEmail"), "(", value, ") {")
+		generateViolation(g, inner+"\t", path, "format", "must be a valid email address")
+		g.P(inner, "}")
+	case "uuid":
+		g.P(inner, "if !", ormPackage.Ident("IsUUID"), "(", value, ") {")
+		generateViolation(g, inner+"\t", path, "format", "must be a valid UUID")
+		g.P(inner, "}")
+	}
+	g.P(indent, "}")
+}
+
+// generateViolation 生成追加一条违规项的代码
+func generateViolation(g *protogen.GeneratedFile, indent, path, rule, message string) {
+	g.P(indent, "violations = append(violations, ", ormPackage.Ident("Violation"), "{Path: ", path, ", Rule: \"", rule, "\", Message: ", strconv.Quote(message), "})")
+}
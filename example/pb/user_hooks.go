@@ -0,0 +1,33 @@
+package pb
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// 手写的生命周期钩子，与生成代码位于同一个包，由生成的UserRepository调用
+
+// ErrInvalidAge 年龄为负数
+var ErrInvalidAge = errors.New("pb: age must not be negative")
+
+// BeforeInsert 插入前校验年龄并规范化邮箱
+func (x *User) BeforeInsert(ctx context.Context) error {
+	if x.GetAge() < 0 {
+		return ErrInvalidAge
+	}
+	x.SetEmail(strings.ToLower(x.GetEmail()))
+	return nil
+}
+
+// BeforeUpdate 拒绝负数年龄，修改了邮箱时规范化为小写，追加的修改随本次更新写入
+func (x *User) BeforeUpdate(ctx context.Context, dirtyFields []int) error {
+	if slices.Contains(dirtyFields, UserAgeFieldIndex) && x.GetAge() < 0 {
+		return ErrInvalidAge
+	}
+	if slices.Contains(dirtyFields, UserEmailFieldIndex) {
+		x.SetEmail(strings.ToLower(x.GetEmail()))
+	}
+	return nil
+}
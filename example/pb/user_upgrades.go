@@ -0,0 +1,29 @@
+package pb
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 手写的文档升级函数，与生成代码位于同一个包，加载旧版本文档时由UnmarshalBSON调用
+
+func init() {
+	RegisterUserUpgrade(1, upgradeUserV1)
+}
+
+// upgradeUserV1 版本1的tags是逗号分隔的字符串，版本2改为数组
+func upgradeUserV1(doc bson.M) error {
+	s, ok := doc["tags"].(string)
+	if !ok {
+		return nil
+	}
+	tags := bson.A{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	doc["tags"] = tags
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ageReport 按年龄分组的统计结果
+type ageReport struct {
+	Age   int32    `bson:"_id"`
+	Count int64    `bson:"count"`
+	Names []string `bson:"names"`
+}
+
+func main() {
+	fmt.Println("=== 测试聚合管道构建 ===")
+
+	// 统计VIP用户的年龄分布，字段改名后这里无法通过编译
+	count := orm.NewField[int64]("count")
+	pipeline := orm.NewPipeline().
+		Match(pb.UserFields.Tags.Contains("vip")).
+		Unwind(pb.UserFields.Tags, false).
+		Group(orm.Ref(pb.UserFields.Age),
+			orm.Count(count.Path()),
+			orm.AddToSet("names", pb.UserFields.Name),
+		).
+		Match(count.Gte(2)).
+		Sort(orm.Desc(count), orm.Asc(orm.NewField[int32]("_id"))).
+		Limit(10)
+	printPipeline(pipeline)
+
+	// 关联查询：profile.avatar_url关联另一集合，关联结果的字段用带前缀的路径引用
+	friends := pb.NewUserFieldPaths("friends.")
+	lookup := orm.NewPipeline().
+		Lookup("users", pb.UserFields.Profile.AvatarUrl, pb.UserFields.Profile.AvatarUrl, "friends").
+		Unwind(friends, true).
+		Project(
+			orm.Include(pb.UserFields.Name),
+			orm.As("friend", orm.Ref(friends.Name)),
+			orm.Exclude(pb.UserFields.Id),
+		)
+	printPipeline(lookup)
+
+	stages := pipeline.Stages()
+	if len(stages) != 6 || stages[2][0].Key != "$group" {
+		panic("管道阶段不正确")
+	}
+
+	// 分组结果解码为临时结构体，使用时为 orm.Aggregate[ageReport](ctx, repo.Collection(), pipeline)
+	raw, err := bson.Marshal(bson.D{{Key: "_id", Value: int32(30)}, {Key: "count", Value: int64(2)}, {Key: "names", Value: bson.A{"张三", "李四"}}})
+	if err != nil {
+		panic(err)
+	}
+	var report ageReport
+	if err := bson.Unmarshal(raw, &report); err != nil || report.Age != 30 || len(report.Names) != 2 {
+		panic("统计结果解码不正确")
+	}
+	fmt.Printf("统计结果: %+v\n", report)
+	fmt.Println("聚合管道测试通过")
+}
+
+func printPipeline(p *orm.Pipeline) {
+	for _, stage := range p.Stages() {
+		data, err := bson.MarshalExtJSON(stage, false, false)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(data))
+	}
+}
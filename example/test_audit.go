@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	fmt.Println("=== 测试审计记录 ===")
+
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: "audit_user"},
+		{Key: "name", Value: "张三"},
+		{Key: "age", Value: int32(20)},
+		{Key: "tags", Value: bson.A{"a"}},
+		{Key: "version", Value: int64(3)},
+		{Key: "profile", Value: bson.D{{Key: "bio", Value: "旧简介"}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	user := pb.NewUser()
+	if err := bson.Unmarshal(raw, user); err != nil {
+		panic(err)
+	}
+
+	// 多次修改同一字段时原值是加载时的值
+	user.SetName("李四")
+	user.SetName("王五")
+	user.AddTagsElement("b")
+	user.GetProfile().SetBio("新简介")
+
+	model, _, changes, err := user.TakeAuditedUpdateModel()
+	if err != nil || model == nil {
+		panic("应生成更新")
+	}
+	byPath := map[string]orm.FieldChange{}
+	for _, change := range changes {
+		byPath[change.Path] = change
+		fmt.Printf("%s: %v -> %v\n", change.Path, change.Old, change.New)
+	}
+	if len(changes) != 3 ||
+		byPath["name"].Old.StringValue() != "张三" || byPath["name"].New.StringValue() != "王五" ||
+		byPath["profile.bio"].Old.StringValue() != "旧简介" {
+		panic("字段修改记录不正确")
+	}
+	if _, ok := byPath["version"]; ok {
+		panic("版本字段不应记录")
+	}
+
+	// 取出后修改实体不影响已记录的值
+	user.AddTagsElement("c")
+	if values, _ := byPath["tags"].New.Array().Values(); len(values) != 2 {
+		panic("修改记录应在取出时序列化")
+	}
+
+	// 仓储绑定审计器后Save写入的记录，操作者从context读取：
+	// repo.WithAuditor(orm.NewAuditor(db.Collection("audit_log"))).Save(orm.WithActor(ctx, "admin"), user)
+	ctx := orm.WithActor(context.Background(), "admin")
+	entry := &orm.AuditEntry{Collection: "users", EntityID: user.PrimaryKey(), Actor: orm.ActorFromContext(ctx), Changes: changes}
+	data, err := bson.MarshalExtJSON(entry, false, false)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("审计记录: %s\n", data)
+	fmt.Println("审计记录测试通过")
+}
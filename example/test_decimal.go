@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func main() {
+	fmt.Println("=== 测试Decimal128金额字段 ===")
+
+	// 十进制精确运算
+	sum, err := orm.MustParseDecimal("0.1").Add(orm.MustParseDecimal("0.2"))
+	if err != nil || !sum.Equal(orm.MustParseDecimal("0.3")) || sum.String() != "0.3" {
+		panic("0.1+0.2应精确等于0.3")
+	}
+	if !orm.MustParseDecimal("1.50").Equal(orm.MustParseDecimal("1.5")) || orm.MustParseDecimal("1.50").String() != "1.50" {
+		panic("按数值比较且保留小数位数")
+	}
+	if orm.NewDecimal(1999, -2).String() != "19.99" {
+		panic("NewDecimal(1999, -2)应为19.99")
+	}
+	if _, err := orm.MustParseDecimal("1e30").Add(orm.MustParseDecimal("1e-30")); !errors.Is(err, orm.ErrDecimalRange) {
+		panic("超出34位有效数字应返回ErrDecimalRange")
+	}
+	if _, err := orm.ParseDecimal("NaN"); err == nil {
+		panic("不支持NaN")
+	}
+
+	// 加载已有文档后累加，更新写为$inc
+	data, _ := bson.Marshal(bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "paid", Value: orm.MustParseDecimal("10.00")},
+	})
+	order := pb.NewOrder()
+	if err := order.UnmarshalBSON(data); err != nil {
+		panic(err)
+	}
+	if order.GetPaid().String() != "10.00" {
+		panic("应解码为10.00，实际为" + order.GetPaid().String())
+	}
+	if err := order.IncPaid(orm.MustParseDecimal("0.10")); err != nil {
+		panic(err)
+	}
+	if err := order.IncPaid(orm.MustParseDecimal("0.10")); err != nil {
+		panic(err)
+	}
+	if order.GetPaid().String() != "10.20" {
+		panic("内存中的值应为10.20，实际为" + order.GetPaid().String())
+	}
+	update := order.BuildUpdate()
+	fmt.Printf("累加更新: %v\n", update)
+	inc, _ := update["$inc"].(bson.M)
+	if d, ok := inc["paid"].(orm.Decimal); !ok || d.String() != "0.20" || update["$set"] != nil {
+		panic("两次累加应合并为一个$inc")
+	}
+	raw, _ := bson.Marshal(update)
+	if bson.Raw(raw).Lookup("$inc", "paid").Type != bson.TypeDecimal128 {
+		panic("$inc的增量应编码为Decimal128")
+	}
+
+	// 数值相等的赋值不产生变更，整体赋值后改为$set
+	order.SetPaid(orm.MustParseDecimal("10.2"))
+	if order.Dirty.PaidIncrement == nil {
+		panic("数值相等的赋值不应改变增量")
+	}
+	order.SetPaid(orm.MustParseDecimal("5"))
+	if err := order.IncPaid(orm.MustParseDecimal("1")); err != nil {
+		panic(err)
+	}
+	update = order.BuildUpdate()
+	if set, _ := update["$set"].(bson.M); set == nil || !set["paid"].(orm.Decimal).Equal(orm.MustParseDecimal("6")) || update["$inc"] != nil {
+		panic("赋值之后的累加应写入$set")
+	}
+
+	// 保存期间的新增量与写入失败的快照合并
+	order.ResetDirty()
+	order.IncPaid(orm.MustParseDecimal("0.2"))
+	snapshot := order.TakeDirty()
+	order.IncPaid(orm.MustParseDecimal("0.3"))
+	order.RestoreDirty(snapshot)
+	inc, _ = order.BuildUpdate()["$inc"].(bson.M)
+	if d, _ := inc["paid"].(orm.Decimal); !d.Equal(orm.MustParseDecimal("0.5")) {
+		panic("快照的增量应与新增量相加")
+	}
+
+	// 字段改为decimal之前写入的浮点数和字符串按十进制读取
+	legacy, _ := bson.Marshal(bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "paid", Value: 19.99}})
+	old := pb.NewOrder()
+	if err := old.UnmarshalBSON(legacy); err != nil {
+		panic(err)
+	}
+	if old.GetPaid().String() != "19.99" {
+		panic("浮点数应按最短十进制表示读取，实际为" + old.GetPaid().String())
+	}
+
+	// min规则按十进制比较
+	invalid := pb.NewOrder()
+	invalid.SetUserId("user_1")
+	invalid.SetRequestId(orm.NewUUID())
+	invalid.SetPaid(orm.MustParseDecimal("-0.01"))
+	var validationErr *orm.ValidationError
+	if err := invalid.Validate(); !errors.As(err, &validationErr) || validationErr.Violations[0].Rule != "min" {
+		panic("负数金额应违反min规则")
+	}
+
+	// $jsonSchema中的类型
+	schema, _ := bson.Marshal(pb.OrderJSONSchema())
+	if t := bson.Raw(schema).Lookup("properties", "paid", "bsonType").StringValue(); t != "decimal" {
+		panic("decimal字段的bsonType应为decimal，实际为" + t)
+	}
+
+	fmt.Println("Decimal128金额字段测试通过")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"DB/example/pb"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	fmt.Println("=== 测试实例比较 ===")
+
+	// 数据库中已保存的用户
+	stored := pb.NewUser()
+	stored.SetId("diff_user")
+	stored.SetName("张三")
+	stored.SetEmail("zhangsan@example.com")
+	stored.SetTags([]string{"a"})
+	stored.SetMetadataValue("level", "1")
+	stored.SetMetadataValue("source", "web")
+	profile := pb.NewUserProfile()
+	profile.SetBio("原始简介")
+	stored.SetProfile(profile)
+	stored.SetVersion(3)
+
+	// 根据外部数据新构造的用户，版本号不参与比较
+	imported := pb.NewUser()
+	imported.SetId("diff_user")
+	imported.SetName("张三")
+	imported.SetEmail("zs@example.com")
+	imported.SetTags([]string{"a"})
+	imported.SetMetadataValue("level", "2")
+	importedProfile := pb.NewUserProfile()
+	importedProfile.SetBio("导入的简介")
+	imported.SetProfile(importedProfile)
+
+	paths, update := pb.DiffUser(stored, imported)
+	fmt.Printf("变更路径: %v\n", paths)
+	fmt.Printf("增量更新: %v\n", update)
+
+	expected := []string{"email", "metadata.level", "metadata.source", "profile.bio"}
+	if !reflect.DeepEqual(paths, expected) {
+		panic("变更路径不正确")
+	}
+	// email曾经叫mail，与脏标记更新一致，有变更时删除旧键名
+	unset, _ := update["$unset"].(bson.M)
+	if _, ok := unset["mail"]; !ok {
+		panic("email变更时应删除旧键名mail")
+	}
+	if _, ok := unset["profile.avatar"]; ok {
+		panic("avatar_url没有变更，不应删除旧键名")
+	}
+
+	// 相同内容没有差异
+	paths, update = pb.DiffUser(stored, stored)
+	if paths != nil || update != nil {
+		panic("相同实例不应有差异")
+	}
+
+	// 嵌套消息一方为空时整体写入
+	imported.SetProfile(nil)
+	paths, _ = pb.DiffUser(stored, imported)
+	fmt.Printf("删除profile后的变更路径: %v\n", paths)
+	fmt.Println("实例比较测试通过")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func main() {
+	fmt.Println("=== 测试字段掩码 ===")
+
+	// 模拟已加载的用户
+	user := pb.NewUser()
+	user.SetId("mask_user")
+	user.SetName("张三")
+	user.SetEmail("zhangsan@example.com")
+	user.SetAge(20)
+	user.ResetDirty()
+
+	// PATCH请求只更新掩码中的字段
+	patch := pb.NewUser()
+	patch.SetName("李四")
+	patch.SetAge(99)
+	patchProfile := pb.NewUserProfile()
+	patchProfile.SetBio("新的简介")
+	patch.SetProfile(patchProfile)
+
+	mask := &fieldmaskpb.FieldMask{Paths: []string{"name", "profile.bio"}}
+	if err := user.ApplyFieldMask(patch, mask); err != nil {
+		panic(err)
+	}
+	fmt.Printf("应用后: name=%s age=%d bio=%s\n", user.GetName(), user.GetAge(), user.GetProfile().GetBio())
+	if user.GetAge() != 20 || user.GetName() != "李四" || user.GetProfile().GetBio() != "新的简介" {
+		panic("字段掩码应用结果不正确")
+	}
+	// 子消息是复制的，不与patch共享
+	if user.GetProfile() == patch.GetProfile() {
+		panic("子消息不应与src共享")
+	}
+
+	// 脏字段转换为字段掩码
+	dirty := user.DirtyFieldMask()
+	fmt.Printf("脏字段掩码: %v\n", dirty.GetPaths())
+	if !reflect.DeepEqual(dirty.GetPaths(), []string{"name", "profile"}) {
+		panic("脏字段掩码不正确")
+	}
+	user.ResetDirty()
+	user.GetProfile().SetAvatarUrl("https://example.com/a.png")
+	fmt.Printf("只修改子字段后的掩码: %v\n", user.DirtyFieldMask().GetPaths())
+
+	// 非法路径整体拒绝，不会部分应用
+	err := user.ApplyFieldMask(patch, &fieldmaskpb.FieldMask{Paths: []string{"age", "unknown"}})
+	fmt.Printf("非法路径: %v\n", err)
+	if err == nil || user.GetAge() != 20 {
+		panic("非法路径应整体拒绝")
+	}
+
+	// 字段掩码转换为查询投影，主键映射为_id，被父路径覆盖的子路径被去掉
+	projection, err := pb.UserProjection(&fieldmaskpb.FieldMask{Paths: []string{"id", "profile.bio", "profile", "tags"}})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("投影: %v\n", projection)
+	expected := bson.D{{Key: "_id", Value: 1}, {Key: "profile", Value: 1}, {Key: "tags", Value: 1}, {Key: orm.SchemaVersionField, Value: 1}}
+	if !reflect.DeepEqual(projection, expected) {
+		panic("投影不正确")
+	}
+	fmt.Println("字段掩码测试通过")
+}
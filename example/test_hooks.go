@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// taggedUser 加载后建立标签集合的派生状态
+type taggedUser struct {
+	*pb.User
+	tagSet map[string]bool
+}
+
+func newTaggedUser() *taggedUser {
+	return &taggedUser{User: pb.NewUser()}
+}
+
+// AfterLoad 解码后根据标签建立派生状态
+func (u *taggedUser) AfterLoad(ctx context.Context) error {
+	u.tagSet = make(map[string]bool)
+	for _, tag := range u.GetTags() {
+		u.tagSet[tag] = true
+	}
+	return nil
+}
+
+func main() {
+	fmt.Println("=== 测试生命周期钩子 ===")
+	ctx := context.Background()
+
+	// pb.User在example/pb/user_hooks.go中实现了BeforeInsert和BeforeUpdate
+	var _ orm.BeforeInserter = (*pb.User)(nil)
+	var _ orm.BeforeUpdater = (*pb.User)(nil)
+
+	user := pb.NewUser()
+	user.SetId("hook_user")
+	user.SetAge(-1)
+	if err := user.BeforeInsert(ctx); !errors.Is(err, pb.ErrInvalidAge) {
+		panic("负数年龄应被拒绝")
+	}
+	user.SetAge(20)
+	user.ResetDirty()
+
+	// Save在构建更新前以脏字段索引调用BeforeUpdate，钩子追加的修改随本次更新写入
+	user.SetEmail("ZhangSan@Example.COM")
+	if err := user.BeforeUpdate(ctx, user.GetDirtyFieldIndexes()); err != nil {
+		panic(err)
+	}
+	model, _, err := user.TakeUpdateModel()
+	if err != nil {
+		panic(err)
+	}
+	email, _ := bson.Raw(model.Update.(bson.Raw)).Lookup("$set", "email").StringValueOK()
+	fmt.Printf("更新中的邮箱: %s\n", email)
+	if email != "zhangsan@example.com" {
+		panic("BeforeUpdate追加的修改应写入更新")
+	}
+
+	user.SetAge(-5)
+	if err := user.BeforeUpdate(ctx, user.GetDirtyFieldIndexes()); !errors.Is(err, pb.ErrInvalidAge) {
+		panic("BeforeUpdate应拒绝负数年龄")
+	}
+
+	// 迭代器和仓储加载实体后调用AfterLoad
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{
+		bson.D{{Key: "_id", Value: "user_001"}, {Key: "tags", Value: bson.A{"vip", "new"}}, {Key: orm.SchemaVersionField, Value: pb.UserSchemaVersion}},
+	}, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	users, err := orm.NewIterator(cursor, newTaggedUser).All(ctx)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("派生的标签集合: %v\n", users[0].tagSet)
+	if !users[0].tagSet["vip"] || users[0].IsDirty() {
+		panic("AfterLoad应建立派生状态")
+	}
+	fmt.Println("生命周期钩子测试通过")
+}
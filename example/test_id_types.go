@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func main() {
+	fmt.Println("=== 测试ObjectID和UUID标识字段 ===")
+
+	// UUID的字符串形式与解析
+	requestID := orm.NewUUID()
+	parsed, err := orm.ParseUUID(requestID.String())
+	if err != nil || parsed != requestID {
+		panic("UUID字符串应能解析回原值")
+	}
+	if requestID.String()[14] != '4' {
+		panic("NewUUID应生成版本4 UUID")
+	}
+	if _, err := orm.ParseUUID("not-a-uuid"); err == nil {
+		panic("非法UUID应解析失败")
+	}
+	fmt.Printf("request_id: %s\n", requestID)
+
+	// 主键为零值时生成ObjectID，已有主键保持不变，不产生脏标记
+	order := pb.NewOrder()
+	order.EnsurePrimaryKey()
+	id := order.GetId()
+	if id.IsZero() || order.IsIdDirty() {
+		panic("EnsurePrimaryKey应生成主键且不标脏")
+	}
+	order.EnsurePrimaryKey()
+	if order.GetId() != id {
+		panic("已有主键不应被替换")
+	}
+	order.SetUserId("user_1")
+	order.SetRequestId(requestID)
+	order.SetAmount(1999)
+
+	// 编码为原生BSON类型
+	data, err := bson.Marshal(order)
+	if err != nil {
+		panic(err)
+	}
+	raw := bson.Raw(data)
+	if oid, ok := raw.Lookup("_id").ObjectIDOK(); !ok || oid != id {
+		panic("主键应编码为ObjectID")
+	}
+	subtype, bin, ok := raw.Lookup("request_id").BinaryOK()
+	if !ok || subtype != bson.TypeBinaryUUID || string(bin) != string(requestID[:]) {
+		panic("UUID应编码为binary subtype 4")
+	}
+	fmt.Printf("BSON: %v\n", raw)
+
+	// 解码后类型和值不变
+	loaded := pb.NewOrder()
+	if err := loaded.UnmarshalBSON(data); err != nil {
+		panic(err)
+	}
+	if loaded.GetId() != id || loaded.GetRequestId() != requestID || loaded.IsDirty() {
+		panic("解码后标识字段应保持原值且不标脏")
+	}
+
+	// 按类型化的字段路径构建查询，值按原生类型编码
+	filter, err := bson.Marshal(pb.OrderFields.Id.Eq(id).D())
+	if err != nil {
+		panic(err)
+	}
+	if oid, ok := bson.Raw(filter).Lookup("_id").ObjectIDOK(); !ok || oid != id {
+		panic("查询条件应使用ObjectID")
+	}
+
+	// JSON中使用字符串形式
+	text, err := json.Marshal(struct {
+		ID        primitive.ObjectID `json:"id"`
+		RequestID orm.UUID           `json:"request_id"`
+	}{id, requestID})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("JSON: %s\n", text)
+
+	// required检查零值
+	empty := pb.NewOrder()
+	empty.SetUserId("user_1")
+	if err := empty.Validate(); !errors.Is(err, orm.ErrValidation) {
+		panic("零值UUID应违反required")
+	}
+	if err := order.Validate(); err != nil {
+		panic(err)
+	}
+
+	// $jsonSchema中的类型
+	schema, _ := bson.Marshal(pb.OrderJSONSchema())
+	if t := bson.Raw(schema).Lookup("properties", "_id", "bsonType").StringValue(); t != "objectId" {
+		panic("主键的bsonType应为objectId，实际为" + t)
+	}
+	if t := bson.Raw(schema).Lookup("properties", "request_id", "bsonType").StringValue(); t != "binData" {
+		panic("UUID的bsonType应为binData，实际为" + t)
+	}
+
+	fmt.Println("ObjectID和UUID标识字段测试通过")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"DB/example/pb"
+)
+
+func main() {
+	fmt.Println("=== 测试字段变更监听 ===")
+
+	user := pb.NewUser()
+	user.SetId("listener_user")
+	user.SetEmail("old@example.com")
+	user.ResetDirty()
+
+	// 模拟按邮箱缓存的用户，邮箱变更时使旧缓存失效
+	cache := map[string]string{"old@example.com": user.GetId()}
+	cancelEmail := user.OnEmailChanged(func(oldValue, newValue string) {
+		fmt.Printf("邮箱变更: %s -> %s，清除旧缓存\n", oldValue, newValue)
+		delete(cache, oldValue)
+	})
+
+	// 订阅所有字段的变更
+	var changes []int
+	cancelAll := user.OnFieldChanged(func(fieldIndex int, oldValue, newValue interface{}) {
+		fmt.Printf("字段%d变更: %v -> %v\n", fieldIndex, oldValue, newValue)
+		changes = append(changes, fieldIndex)
+	})
+
+	user.SetEmail("new@example.com")
+	user.SetEmail("new@example.com") // 值未改变，不触发回调
+	user.AddTagsElement("vip")
+	user.SetMetadataValue("level", "1")
+	user.RemoveMetadataValue("level")
+	user.RemoveMetadataValue("missing") // 键不存在，不触发回调
+	user.RemoveTagsElement(0)
+
+	fmt.Printf("缓存: %v\n", cache)
+	fmt.Printf("变更字段: %v\n", changes)
+	if _, ok := cache["old@example.com"]; ok {
+		panic("旧邮箱缓存未被清除")
+	}
+	if len(changes) != 5 {
+		panic("变更回调次数不正确")
+	}
+
+	// 删除字典键后生成$unset
+	fmt.Printf("增量更新: %v\n", user.BuildUpdate())
+
+	// 取消订阅后不再收到通知
+	cancelEmail()
+	cancelAll()
+	user.SetEmail("other@example.com")
+	if len(changes) != 5 {
+		panic("取消订阅后仍收到回调")
+	}
+	fmt.Println("取消订阅后不再收到通知")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func main() {
+	fmt.Println("=== 测试旧键名迁移 ===")
+
+	// email曾经叫mail，profile.avatar_url曾经叫avatar
+	legacy, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: "legacy_user"},
+		{Key: "name", Value: "张三"},
+		{Key: "mail", Value: "zhangsan@example.com"},
+		{Key: "profile", Value: bson.D{{Key: "avatar", Value: "https://example.com/a.png"}, {Key: "bio", Value: "简介"}}},
+		{Key: "version", Value: int64(3)},
+	})
+	if err != nil {
+		panic(err)
+	}
+	user := pb.NewUser()
+	if err := user.UnmarshalBSON(legacy); err != nil {
+		panic(err)
+	}
+	fmt.Printf("邮箱: %s, 头像: %s\n", user.GetEmail(), user.GetProfile().GetAvatarUrl())
+	if user.GetEmail() != "zhangsan@example.com" || user.GetProfile().GetAvatarUrl() != "https://example.com/a.png" {
+		panic("应从旧键名读取字段")
+	}
+	if !user.IsEmailDirty() || !user.IsProfileDirty() || user.IsNameDirty() {
+		panic("只有从旧键名读取的字段应被标脏")
+	}
+
+	// 下次保存时写入新键名并删除旧键名
+	update := user.BuildUpdate()
+	fmt.Printf("迁移更新: %v\n", update)
+	set, unset := update["$set"].(bson.M), update["$unset"].(bson.M)
+	if set["email"] != "zhangsan@example.com" || set["profile.avatar_url"] != "https://example.com/a.png" {
+		panic("应写入新键名")
+	}
+	if _, ok := unset["mail"]; !ok {
+		panic("应删除旧键名mail")
+	}
+	if _, ok := unset["profile.avatar"]; !ok {
+		panic("应删除旧键名profile.avatar")
+	}
+	if _, ok := set["profile.bio"]; ok {
+		panic("未迁移的嵌套字段不应写入")
+	}
+
+	// 新旧键名同时存在时以新键名为准，不需要迁移
+	current, _ := bson.Marshal(bson.D{
+		{Key: "_id", Value: "current_user"},
+		{Key: "mail", Value: "old@example.com"},
+		{Key: "email", Value: "new@example.com"},
+		{Key: orm.SchemaVersionField, Value: pb.UserSchemaVersion},
+	})
+	other := pb.NewUser()
+	if err := other.UnmarshalBSON(current); err != nil {
+		panic(err)
+	}
+	if other.GetEmail() != "new@example.com" || other.IsDirty() {
+		panic("新键名应优先且不标脏")
+	}
+
+	// 按字段掩码投影时同时查询旧键名，尚未迁移的文档也能部分加载
+	projection, err := pb.UserProjection(&fieldmaskpb.FieldMask{Paths: []string{"email", "profile.avatar_url"}})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("投影: %v\n", projection)
+	keys := map[string]bool{}
+	for _, e := range projection {
+		keys[e.Key] = true
+	}
+	if !keys["email"] || !keys["mail"] || !keys["profile.avatar_url"] || !keys["profile.avatar"] {
+		panic("投影应包含旧键名")
+	}
+
+	// 连接数据库后批量改写整个集合：
+	// progress, err := repo.Migrate(ctx, func(p orm.MigrationProgress) {
+	// 	fmt.Printf("已检查%d，已改写%d\n", p.Scanned, p.Migrated)
+	// })
+	fmt.Println("旧键名迁移测试通过")
+}
@@ -43,6 +43,14 @@ func main() {
 	user.ResetDirty()
 	fmt.Printf("重置脏标记后: IsDirty=%v, DirtyCount=%d\n", user.IsDirty(), user.GetDirtyFieldCount())
 
+	// 只修改版本号时没有可写入的更新，取出更新模型后不再是脏状态
+	user.SetVersion(3)
+	model, _, err := user.TakeUpdateModel()
+	fmt.Printf("只修改版本号: model=%v, IsDirty=%v\n", model, user.IsDirty())
+	if model != nil || err != nil || user.IsDirty() {
+		panic("没有可写入的更新时应清除脏标记")
+	}
+
 	// 演示位图的内存优势
 	fmt.Println("\n=== 位图内存使用比较 ===")
 	fmt.Printf("旧方案(每个字段1个bool): %d bytes\n", 5*1) // 5个字段 * 1 byte per bool
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func main() {
+	fmt.Println("=== 测试键集分页 ===")
+	ctx := context.Background()
+
+	// 按年龄降序，年龄相同时按_id降序
+	sort := orm.Desc(pb.UserFields.Age)
+	printJSON("排序", sort.D())
+
+	// 第一页不需要令牌，条件为空
+	first, err := orm.KeysetFilter(sort, "")
+	if err != nil || len(first.D()) != 0 {
+		panic("第一页不应有键集条件")
+	}
+
+	// 模拟一页中最后一个文档，生成下一页令牌
+	last, err := bson.Marshal(bson.D{{Key: "_id", Value: "user_010"}, {Key: "age", Value: int32(30)}})
+	if err != nil {
+		panic(err)
+	}
+	token, err := orm.NextPageToken(sort, last)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("令牌: %s\n", token)
+
+	keyset, err := orm.KeysetFilter(sort, token)
+	if err != nil {
+		panic(err)
+	}
+	// 业务条件与键集条件合并，即FindPage实际发送的过滤条件
+	printJSON("下一页条件", orm.And(pb.UserFields.Tags.Contains("vip"), keyset).D())
+
+	// 令牌与排序方式绑定
+	if _, err := orm.KeysetFilter(orm.Asc(pb.UserFields.Age), token); !errors.Is(err, orm.ErrInvalidPageToken) {
+		panic("排序方式不一致时应返回ErrInvalidPageToken")
+	}
+	if _, err := orm.KeysetFilter(sort, "not-a-token"); !errors.Is(err, orm.ErrInvalidPageToken) {
+		panic("无效令牌应返回ErrInvalidPageToken")
+	}
+
+	// 流式迭代：每个文档通过UnmarshalBSON解码为干净的实体
+	cursor, err := mongo.NewCursorFromDocuments([]interface{}{
+		bson.D{{Key: "_id", Value: "user_001"}, {Key: "name", Value: "张三"}, {Key: "age", Value: int32(30)}, {Key: orm.SchemaVersionField, Value: pb.UserSchemaVersion}},
+		bson.D{{Key: "_id", Value: "user_002"}, {Key: "name", Value: "李四"}, {Key: "tags", Value: bson.A{"vip"}}, {Key: orm.SchemaVersionField, Value: pb.UserSchemaVersion}},
+	}, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	users, err := orm.NewIterator(cursor, pb.NewUser).All(ctx)
+	if err != nil {
+		panic(err)
+	}
+	for _, user := range users {
+		fmt.Printf("id=%s name=%s age=%d tags=%v dirty=%v\n", user.GetId(), user.GetName(), user.GetAge(), user.GetTags(), user.IsDirty())
+		if user.IsDirty() {
+			panic("迭代得到的实体不应有脏状态")
+		}
+	}
+	if len(users) != 2 || users[1].GetTags()[0] != "vip" {
+		panic("迭代结果不正确")
+	}
+	fmt.Println("键集分页测试通过")
+}
+
+func printJSON(label string, doc bson.D) {
+	data, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s: %s\n", label, data)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func main() {
+	fmt.Println("=== 测试部分加载 ===")
+
+	// 模拟投影查询返回的文档，只包含_id、name、version和profile.bio
+	// 使用仓储时等价于 repo.FindByIDPartial(ctx, id, mask)
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: "partial_user"},
+		{Key: "name", Value: "张三"},
+		{Key: "version", Value: int64(2)},
+		{Key: "profile", Value: bson.D{{Key: "bio", Value: "简介"}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	user := pb.NewUser()
+	if err := bson.Unmarshal(raw, user); err != nil {
+		panic(err)
+	}
+	user.MarkLoaded(&fieldmaskpb.FieldMask{Paths: []string{"name", "version", "profile.bio"}})
+
+	fmt.Printf("IsPartial=%v IsIdLoaded=%v IsNameLoaded=%v IsTagsLoaded=%v IsProfileLoaded=%v Profile.IsBioLoaded=%v Profile.IsAvatarUrlLoaded=%v\n",
+		user.IsPartial(), user.IsIdLoaded(), user.IsNameLoaded(), user.IsTagsLoaded(),
+		user.IsProfileLoaded(), user.GetProfile().IsBioLoaded(), user.GetProfile().IsAvatarUrlLoaded())
+	if !user.IsPartial() || !user.IsIdLoaded() || user.IsTagsLoaded() || user.IsProfileLoaded() || !user.GetProfile().IsBioLoaded() {
+		panic("加载状态不正确")
+	}
+
+	// 修改已加载的字段、整体赋值或按路径更新的字段都可以保存
+	user.SetName("李四")
+	user.SetAge(30)
+	user.SetMetadataValue("level", "2")
+	user.GetProfile().SetAvatarUrl("https://example.com/a.png")
+	model, snapshot, err := user.TakeUpdateModel()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("更新: %v\n", bson.Raw(model.Update.(bson.Raw)))
+	user.RestoreSnapshot(snapshot)
+	user.ResetDirty()
+
+	// 向未加载的数组追加元素会覆盖数据库中的数组，保存时报错且脏状态保留
+	user.AddTagsElement("vip")
+	_, _, err = user.TakeUpdateModel()
+	fmt.Printf("修改未加载的数组: %v\n", err)
+	var unloaded *orm.UnloadedFieldError
+	if !errors.As(err, &unloaded) || unloaded.Path != "tags" || !errors.Is(err, orm.ErrPartialDocument) || !user.IsTagsDirty() {
+		panic("应返回UnloadedFieldError")
+	}
+
+	// 整体赋值后数组不再依赖数据库
+	user.SetTags([]string{"vip"})
+	if _, _, err := user.TakeUpdateModel(); err != nil {
+		panic(err)
+	}
+
+	// 未加载的字典中写入不能用作点分路径的键会整体写入字典，覆盖数据库中的其他键，保存时报错
+	user.SetMetadataValue("a.b", "1")
+	_, _, err = user.TakeUpdateModel()
+	fmt.Printf("未加载的字典写入带点的键: %v\n", err)
+	if !errors.As(err, &unloaded) || unloaded.Path != "metadata" || !user.IsMetadataDirty() {
+		panic("应返回UnloadedFieldError")
+	}
+
+	// 整体赋值后字典不再依赖数据库
+	user.SetMetadata(map[string]string{"a.b": "1"})
+	if _, _, err := user.TakeUpdateModel(); err != nil {
+		panic(err)
+	}
+	fmt.Println("部分加载测试通过")
+}
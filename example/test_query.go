@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	fmt.Println("=== 测试类型安全的查询条件 ===")
+
+	filter := orm.And(
+		pb.UserFields.Email.Eq("zhangsan@example.com"),
+		pb.UserFields.Age.Gte(18),
+		pb.UserFields.Profile.Bio.Regex("^Go", "i"),
+		orm.Or(
+			pb.UserFields.Tags.Contains("vip"),
+			pb.UserFields.Metadata.Key("level").In("2", "3"),
+		),
+		pb.UserFields.Tags.ElemMatch(pb.UserFields.Tags.Elem().Gt("a"), pb.UserFields.Tags.Elem().Lt("m")),
+		pb.UserFields.Profile.Exists(true),
+	)
+	// pb.UserFields.Age.Eq("18") 无法通过编译，值必须是int32
+
+	printFilter(filter)
+
+	// 主键映射为_id
+	byID := pb.UserFields.Id.Eq("user_001").D()
+	printFilter(pb.UserFields.Id.Eq("user_001"))
+	if byID[0].Key != "_id" {
+		panic("主键路径应为_id")
+	}
+	if pb.UserFields.Profile.Bio.Path() != "profile.bio" {
+		panic("嵌套字段路径不正确")
+	}
+
+	// 单个条件的And直接返回该条件，空条件匹配全部
+	if len(orm.And().D()) != 0 || len(orm.And(pb.UserFields.Age.Lt(3)).D()) != 1 {
+		panic("And的简化结果不正确")
+	}
+
+	// 含有.或以$开头的字典键不能作为路径的一段，条件带有错误且组合后保留，保存时整体写入字典
+	unsafe := orm.And(pb.UserFields.Age.Gt(1), pb.UserFields.Metadata.Key("a.b").Eq("x"))
+	fmt.Printf("不安全的字典键: %v\n", unsafe.Err())
+	if !errors.Is(unsafe.Err(), orm.ErrUnsafeMapKey) || pb.UserFields.Metadata.Key("level").Err() != nil {
+		panic("含有.的字典键应被拒绝")
+	}
+	if pipeline := orm.NewPipeline().Match(unsafe); !errors.Is(pipeline.Err(), orm.ErrUnsafeMapKey) {
+		panic("聚合管道应保留条件的错误")
+	}
+	user := pb.NewUser()
+	user.SetMetadataValue("level", "1")
+	user.ResetDirty()
+	user.SetMetadataValue("$where", "x")
+	set, _ := user.BuildUpdate()["$set"].(bson.M)
+	if _, ok := set["metadata"]; !ok || len(set) != 1 {
+		panic(fmt.Sprintf("不安全的字典键应整体写入字典，实际为%v", set))
+	}
+	fmt.Println("查询条件测试通过")
+}
+
+func printFilter(filter orm.Filter) {
+	data, err := bson.MarshalExtJSON(filter.D(), false, false)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(data))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"DB/example/pb"
+)
+
+// 需要使用 --mongo_out=rollback=true:./example 生成代码
+func main() {
+	fmt.Println("=== 测试撤销内存修改 ===")
+
+	// 模拟从数据库加载的用户
+	user := pb.NewUser()
+	user.SetId("rollback_user")
+	user.SetName("张三")
+	user.SetTags([]string{"a", "b"})
+	user.SetMetadataValue("level", "1")
+	profile := pb.NewUserProfile()
+	profile.SetBio("原始简介")
+	user.SetProfile(profile)
+	user.ResetDirty()
+
+	// 处理过程中修改了多个字段
+	user.SetName("李四")
+	user.SetName("王五")
+	user.AddTagsElement("c")
+	user.SetTagsElement(0, "x")
+	user.SetMetadataValue("level", "2")
+	user.RemoveMetadataValue("level")
+	user.GetProfile().SetBio("新简介")
+	fmt.Printf("修改后: name=%s tags=%v metadata=%v bio=%s dirty=%v\n",
+		user.GetName(), user.GetTags(), user.GetMetadata(), user.GetProfile().GetBio(), user.GetDirtyFieldIndexes())
+
+	// 业务规则校验失败，撤销所有修改
+	user.Rollback()
+	fmt.Printf("撤销后: name=%s tags=%v metadata=%v bio=%s dirty=%v\n",
+		user.GetName(), user.GetTags(), user.GetMetadata(), user.GetProfile().GetBio(), user.GetDirtyFieldIndexes())
+
+	if user.GetName() != "张三" || !reflect.DeepEqual(user.GetTags(), []string{"a", "b"}) ||
+		user.GetMetadata()["level"] != "1" || user.GetProfile().GetBio() != "原始简介" || user.IsDirty() {
+		panic("撤销后的状态不正确")
+	}
+
+	// 整体替换的嵌套消息恢复为原对象，且仍能向父对象同步脏标记
+	user.SetProfile(pb.NewUserProfile())
+	user.Rollback()
+	if user.GetProfile() != profile {
+		panic("嵌套消息没有恢复为原对象")
+	}
+	user.GetProfile().SetAvatarUrl("https://example.com/a.png")
+	if !user.IsProfileDirty() {
+		panic("恢复后的嵌套消息没有连接到父对象")
+	}
+
+	// 保存失败时快照合并回去，撤销仍恢复到上次保存的值
+	user.ResetDirty()
+	user.SetAge(30)
+	snapshot := user.TakeDirty()
+	user.SetAge(31)
+	user.RestoreDirty(snapshot)
+	user.Rollback()
+	if user.GetAge() != 0 {
+		panic("合并快照后撤销的结果不正确")
+	}
+	fmt.Println("撤销修改测试通过")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	fmt.Println("=== 测试$jsonSchema校验器生成 ===")
+
+	schema := pb.UserJSONSchema()
+	data, err := bson.MarshalExtJSONIndent(bson.D{{Key: "$jsonSchema", Value: schema}}, false, false, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(data))
+
+	raw, err := bson.Marshal(schema)
+	if err != nil {
+		panic(err)
+	}
+	doc := bson.Raw(raw)
+	lookup := func(path ...string) bson.RawValue {
+		value, err := doc.LookupErr(path...)
+		if err != nil {
+			panic(fmt.Sprintf("schema缺少%v", path))
+		}
+		return value
+	}
+
+	// required规则进入required列表，字段类型与Go类型的编码一致
+	required, _ := lookup("required").Array().Values()
+	if len(required) != 1 || required[0].StringValue() != "name" {
+		panic("required应只包含name")
+	}
+	checks := map[string]string{
+		"age":        "int",
+		"version":    "long",
+		"tags":       "array",
+		"metadata":   "object",
+		"created_at": "long",
+	}
+	for field, bsonType := range checks {
+		if got := lookup("properties", field, "bsonType").StringValue(); got != bsonType {
+			panic(fmt.Sprintf("%s的bsonType应为%s，实际为%s", field, bsonType, got))
+		}
+	}
+	if lookup("properties", "age", "maximum").AsInt64() != 150 {
+		panic("age应限制最大值")
+	}
+	if lookup("properties", "tags", "items", "maxLength").AsInt64() != 16 {
+		panic("数组的取值规则应作用于元素")
+	}
+
+	// 嵌套消息允许为null，非null时使用UserProfileJSONSchema
+	profile := lookup("properties", "profile", "anyOf").Array()
+	nested, err := profile.IndexErr(1)
+	if err != nil {
+		panic(err)
+	}
+	expected, _ := bson.Marshal(pb.UserProfileJSONSchema())
+	if !bytes.Equal(expected, nested.Value().Document()) {
+		panic("profile应引用UserProfileJSONSchema")
+	}
+
+	// 软删除的时间戳允许为null
+	lookup("properties", orm.SoftDeleteField)
+	fmt.Printf("集合名: %s\n", pb.UserCollectionName)
+
+	// 连接数据库后应用校验器，集合不存在时带校验器创建：
+	// pb.ApplyUserSchemaValidator(ctx, client.Database("testdb"))
+	fmt.Println("$jsonSchema校验器生成测试通过")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func main() {
+	fmt.Println("=== 测试文档结构版本 ===")
+
+	// 新写入的文档带有当前版本号
+	user := pb.NewUser()
+	user.SetId("versioned_user")
+	data, err := user.MarshalBSON()
+	if err != nil {
+		panic(err)
+	}
+	if v := bson.Raw(data).Lookup(orm.SchemaVersionField).Int32(); v != pb.UserSchemaVersion {
+		panic(fmt.Sprintf("_v应为%d，实际为%d", pb.UserSchemaVersion, v))
+	}
+
+	// 版本1的tags是逗号分隔的字符串，加载时由example/pb/user_upgrades.go中注册的函数升级
+	v1, _ := bson.Marshal(bson.D{
+		{Key: "_id", Value: "old_user"},
+		{Key: "name", Value: "张三"},
+		{Key: "tags", Value: "vip, new"},
+		{Key: "version", Value: int64(5)},
+		{Key: orm.SchemaVersionField, Value: int32(1)},
+	})
+	old := pb.NewUser()
+	if err := old.UnmarshalBSON(v1); err != nil {
+		panic(err)
+	}
+	fmt.Printf("升级后的标签: %v\n", old.GetTags())
+	if len(old.GetTags()) != 2 || old.GetTags()[1] != "new" {
+		panic("tags应升级为数组")
+	}
+	if !old.IsTagsDirty() || old.IsNameDirty() {
+		panic("只有升级改变了值的字段应被标脏")
+	}
+
+	// 下次保存时写回升级后的字段和当前版本号
+	update := old.UpdateModel().Update.(bson.M)
+	set := update["$set"].(bson.M)
+	fmt.Printf("升级更新: %v\n", update)
+	if set[orm.SchemaVersionField] != pb.UserSchemaVersion || set["tags"] == nil {
+		panic("应写回tags和_v")
+	}
+
+	// 加载升级后修改再Rollback：升级后的值保持标脏，之后保存时与_v一起写回
+	rolled := pb.NewUser()
+	if err := rolled.UnmarshalBSON(v1); err != nil {
+		panic(err)
+	}
+	rolled.AddTagsElement("extra")
+	rolled.SetName("李四")
+	rolled.Rollback()
+	if len(rolled.GetTags()) != 2 || rolled.GetName() != "张三" || !rolled.IsTagsDirty() {
+		panic("Rollback应恢复为升级后的值并保持升级的标脏")
+	}
+	rolled.SetAge(30)
+	set = rolled.UpdateModel().Update.(bson.M)["$set"].(bson.M)
+	fmt.Printf("Rollback后的更新: %v\n", set)
+	if set[orm.SchemaVersionField] != pb.UserSchemaVersion || set["tags"] == nil || set["age"] == nil || set["name"] != nil {
+		panic("Rollback后保存应写回升级后的tags、age和_v")
+	}
+
+	// 没有_v的文档视为版本1，升级没有改变任何字段时不标脏字段，但保存时仍写入_v
+	unversioned, _ := bson.Marshal(bson.D{{Key: "_id", Value: "plain_user"}, {Key: "tags", Value: bson.A{"a"}}})
+	plain := pb.NewUser()
+	if err := plain.UnmarshalBSON(unversioned); err != nil {
+		panic(err)
+	}
+	if len(plain.GetDirtyFieldIndexes()) != 0 {
+		panic("没有变化的升级不应标脏字段")
+	}
+	model := plain.UpdateModel()
+	if model == nil {
+		panic("升级过的文档应写入_v")
+	}
+	if set := model.Update.(bson.M)["$set"].(bson.M); set[orm.SchemaVersionField] != pb.UserSchemaVersion || set["tags"] != nil {
+		panic("只应写入_v")
+	}
+	if plain.TakeDirty().IsEmpty() {
+		panic("只有升级记录的快照不应为空")
+	}
+
+	// 投影结果缺少_v时不升级，保存时不写入_v
+	projection, err := pb.UserProjection(&fieldmaskpb.FieldMask{Paths: []string{"name"}})
+	if err != nil {
+		panic(err)
+	}
+	if projection[len(projection)-1].Key != orm.SchemaVersionField {
+		panic("投影应包含_v")
+	}
+	partial, _ := bson.Marshal(bson.D{{Key: "_id", Value: "plain_user"}, {Key: "name", Value: "张三"}})
+	projected := pb.NewUser()
+	if err := projected.UnmarshalProjectedBSON(partial); err != nil {
+		panic(err)
+	}
+	if projected.IsDirty() {
+		panic("投影结果不应执行升级")
+	}
+	projected.SetName("李四")
+	if set := projected.UpdateModel().Update.(bson.M)["$set"].(bson.M); set[orm.SchemaVersionField] != nil {
+		panic("未升级的文档不应写入_v")
+	}
+
+	// 比当前版本新的文档无法加载
+	future, _ := bson.Marshal(bson.D{{Key: "_id", Value: "future_user"}, {Key: orm.SchemaVersionField, Value: int32(99)}})
+	if err := pb.NewUser().UnmarshalBSON(future); !errors.Is(err, orm.ErrSchemaVersion) {
+		panic("更新版本的文档应返回ErrSchemaVersion")
+	}
+	fmt.Println("文档结构版本测试通过")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	fmt.Println("=== 测试软删除 ===")
+
+	// 仓储查询时合并的删除范围条件：
+	// repo.FindPage/Count 默认排除，repo.WithDeleted() 包含，repo.OnlyDeleted() 只查已删除
+	filter := pb.UserFields.Age.Gte(18)
+	for _, scope := range []orm.DeletedScope{orm.ExcludeDeleted, orm.IncludeDeleted, orm.OnlyDeleted} {
+		data, err := bson.MarshalExtJSON(orm.And(filter, scope.Filter()).D(), false, false)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("范围%d: %s\n", scope, data)
+	}
+	if len(orm.IncludeDeleted.Filter().D()) != 0 {
+		panic("IncludeDeleted不应附加条件")
+	}
+
+	// deleted_at不是消息字段，加载已删除的文档时被忽略，也不会产生脏标记
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: "deleted_user"},
+		{Key: "name", Value: "张三"},
+		{Key: orm.SoftDeleteField, Value: time.Now()},
+		{Key: orm.SchemaVersionField, Value: pb.UserSchemaVersion},
+	})
+	if err != nil {
+		panic(err)
+	}
+	user := pb.NewUser()
+	if err := bson.Unmarshal(raw, user); err != nil {
+		panic(err)
+	}
+	if user.GetName() != "张三" || user.IsDirty() {
+		panic("已删除文档解码不正确")
+	}
+	fmt.Printf("已删除文档: id=%s name=%s dirty=%v\n", user.GetId(), user.GetName(), user.IsDirty())
+
+	// 保存时过滤条件排除已删除的文档，已删除的实体不会被写入
+	user.SetName("李四")
+	saveFilter := user.UpdateModel().Filter.(bson.D)
+	fmt.Printf("保存的过滤条件: %v\n", saveFilter)
+	if last := saveFilter[len(saveFilter)-1]; last.Key != orm.SoftDeleteField || last.Value != nil {
+		panic("保存的过滤条件应排除已删除的文档")
+	}
+
+	// 聚合时删除范围的$match放在$geoNear之后，$geoNear必须是第一个阶段
+	geo := orm.NewPipeline().Stage(bson.D{{Key: "$geoNear", Value: bson.D{{Key: "near", Value: bson.A{0, 0}}, {Key: "distanceField", Value: "dist"}}}})
+	geo.Match(pb.UserFields.Age.Gte(18))
+	scoped := geo.Scoped(orm.ExcludeDeleted.Filter()).Stages()
+	fmt.Printf("加上删除范围的管道: %v\n", scoped)
+	if len(scoped) != 3 || scoped[0][0].Key != "$geoNear" || scoped[1][0].Key != "$match" || len(geo.Stages()) != 2 {
+		panic("删除范围应放在$geoNear之后且不修改原管道")
+	}
+	if plain := orm.NewPipeline().Match(filter).Scoped(orm.ExcludeDeleted.Filter()).Stages(); plain[0][0].Value.(bson.D)[0].Key != orm.SoftDeleteField {
+		panic("删除范围应放在管道最前面")
+	}
+	fmt.Println("软删除测试通过")
+}
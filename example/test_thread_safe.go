@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"DB/example/pb"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 需要使用 --mongo_out=thread_safe=true:./example 生成代码
+// 运行方式: go run -race example/test_thread_safe.go
+func main() {
+	fmt.Println("=== 测试线程安全模式下的并发读写 ===")
+
+	user := pb.NewUser()
+	user.SetId("thread_safe_user")
+	user.SetProfile(pb.NewUserProfile())
+	user.ResetDirty()
+
+	const workers = 8
+	const rounds = 1000
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				switch i % 6 {
+				case 0:
+					user.SetAge(int32(w*rounds + i))
+					user.SetName(fmt.Sprintf("用户%d", w))
+				case 1:
+					user.AddTagsElement(fmt.Sprintf("tag_%d", i))
+					user.SetMetadataValue(fmt.Sprintf("k%d", w), fmt.Sprint(i))
+				case 2:
+					// 子对象通过根对象的锁修改，与父对象的读写互斥
+					user.GetProfile().SetBio(fmt.Sprintf("简介%d", i))
+				case 3:
+					_ = user.GetName()
+					_ = len(user.GetTags())
+					_ = len(user.GetMetadata())
+					_ = user.GetProfile().GetBio()
+				case 4:
+					if _, err := bson.Marshal(user); err != nil {
+						panic(err)
+					}
+					_ = user.BuildUpdate()
+				case 5:
+					// 模拟异步保存：取出脏状态后再合并回去
+					snapshot := user.TakeDirty()
+					_ = user.IsDirty()
+					user.RestoreDirty(snapshot)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	fmt.Printf("标签数量: %d\n", len(user.GetTags()))
+	fmt.Printf("脏字段: %v\n", user.GetDirtyFieldIndexes())
+	fmt.Printf("Profile是否脏: %v\n", user.IsProfileDirty())
+
+	// 所有修改都应保留在脏状态中
+	if !user.IsAgeDirty() || !user.IsTagsDirty() || !user.IsMetadataDirty() || !user.IsProfileDirty() {
+		panic("并发修改后脏标记丢失")
+	}
+	fmt.Println("并发读写完成，没有数据竞争")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func main() {
+	fmt.Println("=== 测试自动时间字段 ===")
+
+	// 模拟已保存的文档，创建和更新时间由Insert写入
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: "ts_user"},
+		{Key: "name", Value: "张三"},
+		{Key: "version", Value: int64(1)},
+		{Key: "created_at", Value: int64(1700000000000)},
+		{Key: "updated_at", Value: int64(1700000000000)},
+		{Key: orm.SchemaVersionField, Value: pb.UserSchemaVersion},
+	})
+	if err != nil {
+		panic(err)
+	}
+	user := pb.NewUser()
+	if err := bson.Unmarshal(raw, user); err != nil {
+		panic(err)
+	}
+
+	// 没有变更时不产生更新，也不修改更新时间
+	if model, _, _ := user.TakeUpdateModel(); model != nil || user.GetUpdatedAt() != 1700000000000 {
+		panic("没有变更时不应写入更新时间")
+	}
+
+	// 任何增量更新都带上更新时间，调用方不需要手动SetUpdatedAt
+	before := time.Now().UnixMilli()
+	user.SetName("李四")
+	model, _, err := user.TakeUpdateModel()
+	if err != nil {
+		panic(err)
+	}
+	update := bson.Raw(model.Update.(bson.Raw))
+	fmt.Printf("更新: %v\n", update)
+	stamped, ok := update.Lookup("$set", "updated_at").Int64OK()
+	if !ok || stamped < before || user.GetUpdatedAt() != stamped {
+		panic("更新时间不正确")
+	}
+	if _, err := update.LookupErr("$set", "created_at"); err == nil {
+		panic("更新不应修改创建时间")
+	}
+	if user.IsDirty() {
+		panic("同步更新时间不应产生脏标记")
+	}
+	fmt.Printf("created_at=%d updated_at=%d\n", user.GetCreatedAt(), user.GetUpdatedAt())
+	fmt.Println("自动时间字段测试通过")
+}
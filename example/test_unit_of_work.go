@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	fmt.Println("=== 测试工作单元批量保存 ===")
+
+	ctx := context.TODO()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+
+	repo := pb.NewUserRepository(client.Database("testdb").Collection("users"))
+
+	// 准备数据
+	for i := 0; i < 5; i++ {
+		u := pb.NewUser()
+		u.SetId(fmt.Sprintf("uow_user_%d", i))
+		u.SetName(fmt.Sprintf("用户%d", i))
+		repo.Delete(ctx, u.GetId())
+		if err := repo.Insert(ctx, u); err != nil {
+			log.Fatal("插入失败:", err)
+		}
+	}
+
+	// 一次请求中通过绑定工作单元的仓储加载多个实体，加载的实体自动登记
+	uow := orm.NewUnitOfWork()
+	uowRepo := repo.WithUnitOfWork(uow)
+	var users []*pb.User
+	for i := 0; i < 5; i++ {
+		u, err := uowRepo.FindByID(ctx, fmt.Sprintf("uow_user_%d", i))
+		if err != nil {
+			log.Fatal(err)
+		}
+		users = append(users, u)
+	}
+
+	// 只修改其中一部分
+	users[0].SetAge(20)
+	users[2].SetMetadataValue("level", "高级")
+	users[4].AddTagsElement("vip")
+
+	// 标识映射：再次加载同一用户得到同一个对象，修改不会互相覆盖
+	again, err := uowRepo.FindByID(ctx, "uow_user_0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	again.SetName("再次加载后修改")
+	fmt.Printf("再次加载是否为同一对象: %t\n", again == users[0])
+
+	// 工作单元外构造的同主键对象不能再登记
+	other := pb.NewUser()
+	other.SetId("uow_user_0")
+	err = uow.Register(repo.Collection(), other)
+	fmt.Printf("登记同主键的其他对象: %v\n", err)
+
+	// 一次BulkWrite写回所有脏实体
+	if err := uow.Commit(ctx); err != nil {
+		log.Fatal("提交失败:", err)
+	}
+
+	dirty := 0
+	for _, u := range users {
+		if u.IsDirty() {
+			dirty++
+		}
+	}
+	fmt.Printf("提交后仍有脏数据的实体数量: %d\n", dirty)
+	fmt.Printf("users[0]版本: %d, users[1]版本: %d\n", users[0].GetVersion(), users[1].GetVersion())
+
+	if dirty == 0 && again == users[0] && users[0].GetVersion() == 1 && users[1].GetVersion() == 0 {
+		fmt.Println("✅ 工作单元批量保存正常")
+	} else {
+		fmt.Println("❌ 工作单元批量保存存在问题")
+	}
+
+	// 部分文档版本冲突：已写入的文档同步版本号，只有冲突的文档恢复脏状态
+	conflictUow := orm.NewUnitOfWork()
+	conflictRepo := repo.WithUnitOfWork(conflictUow)
+	first, err := conflictRepo.FindByID(ctx, "uow_user_1")
+	if err != nil {
+		log.Fatal(err)
+	}
+	second, err := conflictRepo.FindByID(ctx, "uow_user_3")
+	if err != nil {
+		log.Fatal(err)
+	}
+	first.SetAge(30)
+	second.SetAge(40)
+	concurrent, err := repo.FindByID(ctx, "uow_user_3")
+	if err != nil {
+		log.Fatal(err)
+	}
+	concurrent.SetName("其他请求修改")
+	if err := repo.Save(ctx, concurrent); err != nil {
+		log.Fatal(err)
+	}
+	err = conflictUow.Commit(ctx)
+	fmt.Printf("部分冲突的提交: %v\n", err)
+	if errors.Is(err, orm.ErrVersionConflict) && !first.IsDirty() && first.GetVersion() == 1 && second.IsAgeDirty() && second.GetVersion() == 0 {
+		fmt.Println("✅ 部分冲突只恢复未写入的文档")
+	} else {
+		fmt.Println("❌ 部分冲突的处理存在问题")
+	}
+
+	// 有序BulkWrite中途出错：出错之前的模型已经执行，同步版本号；出错的及之后的恢复脏状态
+	failUow := orm.NewUnitOfWork()
+	failRepo := repo.WithUnitOfWork(failUow)
+	var batch []*pb.User
+	for _, id := range []string{"uow_user_0", "uow_user_2", "uow_user_4"} {
+		u, err := failRepo.FindByID(ctx, id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		batch = append(batch, u)
+	}
+	versions := []int64{batch[0].GetVersion(), batch[1].GetVersion(), batch[2].GetVersion()}
+	batch[0].SetAge(50)
+	batch[1].SetMetadataValue("level", "普通")
+	batch[2].SetAge(60)
+	// metadata被改为字符串后无法再写入metadata.level，第二个模型写入失败
+	_, err = repo.Collection().UpdateOne(ctx, bson.M{"_id": "uow_user_2"}, bson.M{"$set": bson.M{"metadata": "broken"}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = failUow.Commit(ctx)
+	fmt.Printf("中途出错的提交: %v\n", err)
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) &&
+		!batch[0].IsDirty() && batch[0].GetVersion() == versions[0]+1 &&
+		batch[1].IsMetadataDirty() && batch[1].GetVersion() == versions[1] &&
+		batch[2].IsAgeDirty() && batch[2].GetVersion() == versions[2] {
+		fmt.Println("✅ 中途出错只恢复未执行的文档")
+	} else {
+		fmt.Println("❌ 中途出错的处理存在问题")
+	}
+	repo.Collection().UpdateOne(ctx, bson.M{"_id": "uow_user_2"}, bson.M{"$set": bson.M{"metadata": bson.M{}}})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"DB/example/pb"
+	"DB/orm"
+)
+
+func main() {
+	fmt.Println("=== 测试字段校验规则 ===")
+
+	user := pb.NewUser()
+	user.SetId("validate_user")
+	user.SetEmail("not-an-email")
+	user.SetAge(200)
+	user.SetTags([]string{"vip", "Bad Tag", ""})
+	profile := pb.NewUserProfile()
+	profile.SetAvatarUrl("ftp://example.com/a.png")
+	user.SetProfile(profile)
+
+	// Validate返回所有违反的规则，路径与文档路径一致
+	err := user.Validate()
+	var verr *orm.ValidationError
+	if !errors.As(err, &verr) || !errors.Is(err, orm.ErrValidation) {
+		panic("应返回*orm.ValidationError")
+	}
+	got := make(map[string]string)
+	for _, v := range verr.Violations {
+		fmt.Printf("  %s [%s]\n", v, v.Rule)
+		got[v.Path] = v.Rule
+	}
+	expected := map[string]string{
+		"name":               "required",
+		"email":              "format",
+		"age":                "max",
+		"tags.1":             "pattern",
+		"tags.2":             "min_len",
+		"profile.avatar_url": "pattern",
+	}
+	for path, rule := range expected {
+		if got[path] != rule {
+			panic(fmt.Sprintf("%s应违反%s规则", path, rule))
+		}
+	}
+	if len(verr.Violations) != len(expected) {
+		panic("违反的规则数量不符")
+	}
+
+	// TrySet违反规则时返回错误且不修改字段
+	user.ResetDirty()
+	if err := user.TrySetAge(-1); !errors.Is(err, orm.ErrValidation) {
+		panic("负数年龄应被拒绝")
+	}
+	if user.GetAge() != 200 || user.IsDirty() {
+		panic("校验失败时不应修改字段")
+	}
+	if err := user.TrySetAge(30); err != nil {
+		panic(err)
+	}
+	if user.GetAge() != 30 || !user.IsDirty() {
+		panic("校验通过时应设置字段")
+	}
+
+	// 修正后校验通过
+	user.SetName("张三")
+	user.SetEmail("zhangsan@example.com")
+	user.SetTags([]string{"vip", "new_user"})
+	if err := user.GetProfile().TrySetAvatarUrl("https://example.com/a.png"); err != nil {
+		panic(err)
+	}
+	if err := user.Validate(); err != nil {
+		panic(err)
+	}
+	fmt.Println("字段校验规则测试通过")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"DB/example/pb"
+	"DB/orm"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	fmt.Println("=== 测试乐观锁版本字段 ===")
+
+	ctx := context.TODO()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+
+	repo := pb.NewUserRepository(client.Database("testdb").Collection("users"))
+
+	user := pb.NewUser()
+	user.SetId("version_user")
+	user.SetName("张三")
+	repo.Delete(ctx, user.GetId())
+	if err := repo.Insert(ctx, user); err != nil {
+		log.Fatal("插入失败:", err)
+	}
+
+	// 两个服务分别加载同一个用户
+	a, err := repo.FindByID(ctx, "version_user")
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := repo.FindByID(ctx, "version_user")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("加载后版本: a=%d, b=%d\n", a.GetVersion(), b.GetVersion())
+
+	// a先保存，版本自增
+	a.SetName("李四")
+	if err := repo.Save(ctx, a); err != nil {
+		log.Fatal("保存失败:", err)
+	}
+	fmt.Printf("a保存后版本: %d, 是否有脏数据: %t\n", a.GetVersion(), a.IsDirty())
+
+	// b使用旧版本保存，应该冲突
+	b.SetEmail("lisi@example.com")
+	err = repo.Save(ctx, b)
+	fmt.Printf("b保存结果: %v\n", err)
+
+	if errors.Is(err, orm.ErrVersionConflict) && b.IsDirty() {
+		fmt.Println("✅ 版本冲突检测正常，b的修改未被覆盖写入")
+	} else {
+		fmt.Println("❌ 版本冲突检测存在问题")
+	}
+}
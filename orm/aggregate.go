@@ -0,0 +1,252 @@
+package orm
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Pipeline 聚合管道构建器，各阶段的字段通过生成的字段路径引用
+// 字段改名后引用它的报表代码无法通过编译；方法原地追加阶段并返回自身以便链式调用
+type Pipeline struct {
+	stages mongo.Pipeline
+	err    error // 构建阶段时的错误，执行聚合时返回
+}
+
+// NewPipeline 创建空的聚合管道
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// Stages 返回所有阶段，可直接传给Collection.Aggregate
+func (p *Pipeline) Stages() mongo.Pipeline {
+	if p.stages == nil {
+		return mongo.Pipeline{}
+	}
+	return p.stages
+}
+
+// Err 返回构建阶段时的第一个错误，如$match条件中的字典键无法用作字段路径
+func (p *Pipeline) Err() error {
+	return p.err
+}
+
+// Stage 追加任意阶段，用于构建器未覆盖的操作
+func (p *Pipeline) Stage(stage bson.D) *Pipeline {
+	p.stages = append(p.stages, stage)
+	return p
+}
+
+// Append 追加另一个管道的所有阶段
+func (p *Pipeline) Append(other *Pipeline) *Pipeline {
+	p.stages = append(p.stages, other.stages...)
+	if p.err == nil {
+		p.err = other.err
+	}
+	return p
+}
+
+// leadingStages 只能作为管道第一个阶段的操作
+var leadingStages = map[string]bool{"$geoNear": true, "$search": true, "$searchMeta": true, "$vectorSearch": true}
+
+// Scoped 返回在最前面加上filter对应$match阶段的新管道，不修改原管道，用于给调用方的管道加上查询范围
+// 第一个阶段是$geoNear等只能位于开头的操作时，$match放在它之后
+func (p *Pipeline) Scoped(filter Filter) *Pipeline {
+	scoped := NewPipeline()
+	rest := p.stages
+	if len(rest) > 0 && len(rest[0]) > 0 && leadingStages[rest[0][0].Key] {
+		scoped.Stage(rest[0])
+		rest = rest[1:]
+	}
+	scoped.Match(filter)
+	scoped.stages = append(scoped.stages, rest...)
+	if scoped.err == nil {
+		scoped.err = p.err
+	}
+	return scoped
+}
+
+// Match 追加$match阶段
+func (p *Pipeline) Match(filter Filter) *Pipeline {
+	if p.err == nil {
+		p.err = filter.Err()
+	}
+	return p.Stage(bson.D{{Key: "$match", Value: filter.D()}})
+}
+
+// Group 追加$group阶段，按key分组，key为nil时所有文档归为一组
+// 分组键可以是Ref返回的字段引用，也可以是由字段引用组成的文档
+func (p *Pipeline) Group(key interface{}, accumulators ...Accumulator) *Pipeline {
+	group := bson.D{{Key: "_id", Value: key}}
+	for _, acc := range accumulators {
+		group = append(group, bson.E{Key: acc.Name, Value: bson.D{{Key: acc.Op, Value: acc.Expr}}})
+	}
+	return p.Stage(bson.D{{Key: "$group", Value: group}})
+}
+
+// Project 追加$project阶段
+func (p *Pipeline) Project(items ...ProjectItem) *Pipeline {
+	project := bson.D{}
+	for _, item := range items {
+		project = append(project, bson.E{Key: item.name, Value: item.value})
+	}
+	return p.Stage(bson.D{{Key: "$project", Value: project}})
+}
+
+// Sort 追加$sort阶段，按参数顺序排序，不追加_id
+func (p *Pipeline) Sort(sorts ...Sort) *Pipeline {
+	spec := bson.D{}
+	for _, s := range sorts {
+		dir := 1
+		if s.Descending {
+			dir = -1
+		}
+		spec = append(spec, bson.E{Key: s.Path, Value: dir})
+	}
+	return p.Stage(bson.D{{Key: "$sort", Value: spec}})
+}
+
+// Skip 追加$skip阶段
+func (p *Pipeline) Skip(n int64) *Pipeline {
+	return p.Stage(bson.D{{Key: "$skip", Value: n}})
+}
+
+// Limit 追加$limit阶段
+func (p *Pipeline) Limit(n int64) *Pipeline {
+	return p.Stage(bson.D{{Key: "$limit", Value: n}})
+}
+
+// Lookup 追加$lookup阶段，按localField等于from集合中的foreignField关联，结果数组写入as字段
+// 关联文档的字段可以用以"as."为前缀创建的字段路径引用
+func (p *Pipeline) Lookup(from string, localField, foreignField Pather, as string) *Pipeline {
+	return p.Stage(bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: from},
+		{Key: "localField", Value: localField.Path()},
+		{Key: "foreignField", Value: foreignField.Path()},
+		{Key: "as", Value: as},
+	}}})
+}
+
+// Unwind 追加$unwind阶段，将数组字段展开为每个元素一个文档
+// preserveEmpty为true时保留数组为空或字段不存在的文档
+func (p *Pipeline) Unwind(field Pather, preserveEmpty bool) *Pipeline {
+	if !preserveEmpty {
+		return p.Stage(bson.D{{Key: "$unwind", Value: Ref(field)}})
+	}
+	return p.Stage(bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: Ref(field)},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}})
+}
+
+// Ref 返回聚合表达式中对字段的引用，即"$"加字段路径
+func Ref(field Pather) string {
+	return "$" + field.Path()
+}
+
+// Accumulator $group阶段的累加器，Name为结果文档中的字段名
+type Accumulator struct {
+	Name string
+	Op   string
+	Expr interface{}
+}
+
+// Sum 对字段求和
+func Sum(name string, field Pather) Accumulator {
+	return Accumulator{Name: name, Op: "$sum", Expr: Ref(field)}
+}
+
+// Count 统计组内文档数
+func Count(name string) Accumulator {
+	return Accumulator{Name: name, Op: "$sum", Expr: 1}
+}
+
+// Avg 求字段平均值
+func Avg(name string, field Pather) Accumulator {
+	return Accumulator{Name: name, Op: "$avg", Expr: Ref(field)}
+}
+
+// Min 求字段最小值
+func Min(name string, field Pather) Accumulator {
+	return Accumulator{Name: name, Op: "$min", Expr: Ref(field)}
+}
+
+// Max 求字段最大值
+func Max(name string, field Pather) Accumulator {
+	return Accumulator{Name: name, Op: "$max", Expr: Ref(field)}
+}
+
+// First 取组内第一个文档的字段值
+func First(name string, field Pather) Accumulator {
+	return Accumulator{Name: name, Op: "$first", Expr: Ref(field)}
+}
+
+// Last 取组内最后一个文档的字段值
+func Last(name string, field Pather) Accumulator {
+	return Accumulator{Name: name, Op: "$last", Expr: Ref(field)}
+}
+
+// Push 将组内所有字段值收集为数组
+func Push(name string, field Pather) Accumulator {
+	return Accumulator{Name: name, Op: "$push", Expr: Ref(field)}
+}
+
+// AddToSet 将组内字段值去重后收集为数组
+func AddToSet(name string, field Pather) Accumulator {
+	return Accumulator{Name: name, Op: "$addToSet", Expr: Ref(field)}
+}
+
+// ProjectItem $project阶段的一项
+type ProjectItem struct {
+	name  string
+	value interface{}
+}
+
+// Include 保留字段
+func Include(field Pather) ProjectItem {
+	return ProjectItem{name: field.Path(), value: 1}
+}
+
+// Exclude 去掉字段
+func Exclude(field Pather) ProjectItem {
+	return ProjectItem{name: field.Path(), value: 0}
+}
+
+// As 输出名为name的计算字段，expr为聚合表达式，如Ref(field)
+func As(name string, expr interface{}) ProjectItem {
+	return ProjectItem{name: name, value: expr}
+}
+
+// Aggregate 执行聚合并将结果解码为T，T通常是报表使用的临时结构体
+// 分组或计算得到的字段可用NewField创建路径后在后续阶段引用
+func Aggregate[T any](ctx context.Context, collection *mongo.Collection, p *Pipeline, opts ...*options.AggregateOptions) ([]T, error) {
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	cursor, err := collection.Aggregate(ctx, p.Stages(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	var results []T
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AggregateIterator 执行聚合并逐个将结果解码为生成类型，生成的仓储使用
+// 结果可能经过$project等阶段，实现了ProjectedUnmarshaler的类型不执行结构升级
+func AggregateIterator[T Unmarshaler](ctx context.Context, collection *mongo.Collection, p *Pipeline, newFn func() T, opts ...*options.AggregateOptions) (*Iterator[T], error) {
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	cursor, err := collection.Aggregate(ctx, p.Stages(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	it := NewIterator(cursor, newFn)
+	it.projected = true
+	return it, nil
+}
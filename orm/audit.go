@@ -0,0 +1,118 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrAuditWrite 非事务模式下更新已经写入、审计记录写入失败，可用errors.Is判断
+// 此时实体的修改已保存，调用方可按需补写审计记录
+var ErrAuditWrite = errors.New("orm: audit entry not written")
+
+// actorKey 操作者在context中的键
+type actorKey struct{}
+
+// WithActor 返回携带操作者的context，审计记录从中读取操作者
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext 返回context中的操作者，未设置时返回空字符串
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// FieldChange 一个字段的修改，值在取出脏状态时序列化，与之后对实体的修改无关
+// 原值未知（如修改前未记录）时Old为null
+type FieldChange struct {
+	Path string        `bson:"path"`
+	Old  bson.RawValue `bson:"old"`
+	New  bson.RawValue `bson:"new"`
+}
+
+// NewFieldChange 创建字段修改记录，生成代码使用
+func NewFieldChange(path string, oldValue, newValue interface{}) FieldChange {
+	return FieldChange{Path: path, Old: rawValue(oldValue), New: rawValue(newValue)}
+}
+
+// rawValue 把字段值序列化为BSON值，生成类型的字段值总能序列化，失败时记为null
+func rawValue(v interface{}) bson.RawValue {
+	t, data, err := bson.MarshalValue(v)
+	if err != nil {
+		return bson.RawValue{Type: bson.TypeNull}
+	}
+	return bson.RawValue{Type: t, Value: data}
+}
+
+// AuditEntry 审计集合中的一条记录
+type AuditEntry struct {
+	Collection string        `bson:"collection"`
+	EntityID   interface{}   `bson:"entity_id"`
+	Actor      string        `bson:"actor,omitempty"`
+	Changes    []FieldChange `bson:"changes"`
+	Timestamp  time.Time     `bson:"timestamp"`
+}
+
+// Auditor 把实体的字段修改写入审计集合，与实体的更新在同一事务中提交
+// 事务要求审计集合与实体集合属于同一个Client，并且需要副本集或分片集群
+type Auditor struct {
+	collection    *mongo.Collection
+	transactional bool
+}
+
+// NewAuditor 创建写入collection的审计器，默认在事务中写入
+func NewAuditor(collection *mongo.Collection) *Auditor {
+	return &Auditor{collection: collection, transactional: true}
+}
+
+// SetTransactional 设置是否在事务中写入，单机部署不支持事务时可关闭，此时先更新后写审计记录
+// 关闭后审计记录写入失败时更新不会回滚，Record返回包装了ErrAuditWrite的错误
+func (a *Auditor) SetTransactional(transactional bool) {
+	a.transactional = transactional
+}
+
+// Collection 返回审计集合
+func (a *Auditor) Collection() *mongo.Collection {
+	return a.collection
+}
+
+// Record 执行update并写入审计记录，update返回错误时不写入审计记录
+// entry未设置操作者和时间时从ctx读取操作者并使用当前时间；事务模式下update可能随事务重试多次
+func (a *Auditor) Record(ctx context.Context, entry *AuditEntry, update func(ctx context.Context) error) error {
+	if entry.Actor == "" {
+		entry.Actor = ActorFromContext(ctx)
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if !a.transactional {
+		if err := update(ctx); err != nil {
+			return err
+		}
+		if _, err := a.collection.InsertOne(ctx, entry); err != nil {
+			return fmt.Errorf("%w: %w", ErrAuditWrite, err)
+		}
+		return nil
+	}
+
+	session, err := a.collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if err := update(sessCtx); err != nil {
+			return nil, err
+		}
+		_, err := a.collection.InsertOne(sessCtx, entry)
+		return nil, err
+	})
+	return err
+}
@@ -0,0 +1,207 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrDecimalRange 运算结果超出Decimal128的34位有效数字或指数范围，无法精确表示，可用errors.Is判断
+var ErrDecimalRange = errors.New("orm: decimal result is not exactly representable")
+
+// Decimal 声明了decimal的字段在生成结构体中的类型，存储为Decimal128，按十进制精确比较和运算
+// 零值表示0；保留小数位数，1.50与1.5相等但字符串形式不同；不支持NaN和无穷
+type Decimal struct {
+	value primitive.Decimal128
+}
+
+// ParseDecimal 解析十进制字符串，支持科学计数法，如"19.99"、"-1e3"
+func ParseDecimal(s string) (Decimal, error) {
+	d, err := primitive.ParseDecimal128(s)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return decimalFrom128(d)
+}
+
+// MustParseDecimal 解析十进制字符串，失败时panic，用于常量
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewDecimal 返回coefficient×10^exp，如NewDecimal(1999, -2)为19.99；指数超出Decimal128范围时panic
+func NewDecimal(coefficient int64, exp int) Decimal {
+	d, ok := newDecimal(big.NewInt(coefficient), exp)
+	if !ok {
+		panic(fmt.Sprintf("orm: decimal exponent %d out of range", exp))
+	}
+	return d
+}
+
+// NewDecimalFromInt 返回整数v
+func NewDecimalFromInt(v int64) Decimal {
+	return NewDecimal(v, 0)
+}
+
+// newDecimal 由系数和指数构造，不能精确表示时返回false，所有的0都规范为零值
+func newDecimal(coefficient *big.Int, exp int) (Decimal, bool) {
+	if coefficient.Sign() == 0 {
+		return Decimal{}, true
+	}
+	d, ok := primitive.ParseDecimal128FromBigInt(coefficient, exp)
+	if !ok {
+		return Decimal{}, false
+	}
+	return Decimal{value: d}, true
+}
+
+// decimalFrom128 转换驱动的Decimal128，拒绝NaN和无穷
+func decimalFrom128(d primitive.Decimal128) (Decimal, error) {
+	coefficient, exp, err := d.BigInt()
+	if err != nil {
+		return Decimal{}, fmt.Errorf("orm: invalid decimal %s: %w", d, err)
+	}
+	result, _ := newDecimal(coefficient, exp)
+	return result, nil
+}
+
+// parts 返回系数和指数，零值返回0和0
+func (d Decimal) parts() (*big.Int, int) {
+	if d.IsZero() {
+		return new(big.Int), 0
+	}
+	coefficient, exp, _ := d.value.BigInt()
+	return coefficient, exp
+}
+
+// aligned 把两个值的系数对齐到较小的指数
+func aligned(a, b Decimal) (*big.Int, *big.Int, int) {
+	ac, ae := a.parts()
+	bc, be := b.parts()
+	exp := min(ae, be)
+	ac.Mul(ac, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(ae-exp)), nil))
+	bc.Mul(bc, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(be-exp)), nil))
+	return ac, bc, exp
+}
+
+// Decimal128 返回驱动的Decimal128值，零值为0
+func (d Decimal) Decimal128() primitive.Decimal128 {
+	if d.IsZero() {
+		return primitive.NewDecimal128(0x3040000000000000, 0)
+	}
+	return d.value
+}
+
+// IsZero 是否为0，decimal字段的required规则检查此值
+func (d Decimal) IsZero() bool {
+	return d.value == primitive.Decimal128{}
+}
+
+// Sign 返回-1、0或1
+func (d Decimal) Sign() int {
+	coefficient, _ := d.parts()
+	return coefficient.Sign()
+}
+
+// Cmp 按数值比较，d小于、等于、大于o时分别返回-1、0、1
+func (d Decimal) Cmp(o Decimal) int {
+	if d.IsZero() || o.IsZero() {
+		return d.Sign() - o.Sign()
+	}
+	a, b, _ := aligned(d, o)
+	return a.Cmp(b)
+}
+
+// Equal 数值是否相等，不比较小数位数，生成的Setter用它判断值是否改变
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+// Add 返回d+o，结果保留两者中较多的小数位数；不能精确表示时返回ErrDecimalRange
+func (d Decimal) Add(o Decimal) (Decimal, error) {
+	if o.IsZero() {
+		return d, nil
+	}
+	if d.IsZero() {
+		return o, nil
+	}
+	a, b, exp := aligned(d, o)
+	result, ok := newDecimal(a.Add(a, b), exp)
+	if !ok {
+		return Decimal{}, fmt.Errorf("%w: %s + %s", ErrDecimalRange, d, o)
+	}
+	return result, nil
+}
+
+// Sub 返回d-o，不能精确表示时返回ErrDecimalRange
+func (d Decimal) Sub(o Decimal) (Decimal, error) {
+	return d.Add(o.Neg())
+}
+
+// Neg 返回-d
+func (d Decimal) Neg() Decimal {
+	coefficient, exp := d.parts()
+	result, _ := newDecimal(coefficient.Neg(coefficient), exp)
+	return result
+}
+
+// String 返回十进制字符串，零值为"0"
+func (d Decimal) String() string {
+	if d.IsZero() {
+		return "0"
+	}
+	return d.value.String()
+}
+
+// MarshalText JSON等文本格式中使用字符串形式，避免转换为浮点数
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText 解析字符串形式的十进制数
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalBSONValue 编码为Decimal128
+func (d Decimal) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(d.Decimal128())
+}
+
+// UnmarshalBSONValue 解码Decimal128；字段改为decimal之前写入的字符串、整数和浮点数按十进制读取，null解码为0
+// 浮点数按最短的十进制表示转换，如0.1读取为0.1而不是其二进制近似值
+func (d *Decimal) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+	var err error
+	switch t {
+	case bson.TypeDecimal128:
+		*d, err = decimalFrom128(value.Decimal128())
+	case bson.TypeString:
+		*d, err = ParseDecimal(value.StringValue())
+	case bson.TypeInt32:
+		*d = NewDecimalFromInt(int64(value.Int32()))
+	case bson.TypeInt64:
+		*d = NewDecimalFromInt(value.Int64())
+	case bson.TypeDouble:
+		*d, err = ParseDecimal(strconv.FormatFloat(value.Double(), 'g', -1, 64))
+	case bson.TypeNull:
+		*d = Decimal{}
+	default:
+		return fmt.Errorf("orm: cannot decode %v as decimal", t)
+	}
+	return err
+}
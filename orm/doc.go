@@ -0,0 +1,2 @@
+// Package orm 是protoc-gen-mongo生成代码的运行时支持库
+package orm
@@ -0,0 +1,57 @@
+package orm
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Document 带主键的生成类型，protoc-gen-mongo为标记了primary_key的消息实现该接口
+type Document interface {
+	// PrimaryKey 返回主键值，用于标识映射
+	PrimaryKey() interface{}
+	// IsDirty 检查是否有未保存的变更
+	IsDirty() bool
+	// ResetDirty 清除所有脏标记
+	ResetDirty()
+	// UpdateModel 根据脏标记构建UpdateOne模型，没有变更时返回nil，不改变脏状态
+	UpdateModel() *mongo.UpdateOneModel
+	// TakeUpdateModel 构建UpdateOne模型并取出脏状态，没有可写入的变更时清除脏标记并返回nil
+	// 取出后写入期间的新修改会重新标脏，不会被本次写入的结果清除
+	TakeUpdateModel() (*mongo.UpdateOneModel, DirtySnapshot, error)
+	// RestoreSnapshot 写入失败时把取出的脏状态合并回实体
+	RestoreSnapshot(snapshot DirtySnapshot)
+	// MarkSaved 写入成功后调用，同步版本号
+	MarkSaved()
+}
+
+// AppliedFilterer 带版本号的生成类型实现的可选接口
+// 工作单元的批量写入只匹配了部分文档时，据此逐个判断哪些文档已经写入
+type AppliedFilterer interface {
+	// AppliedFilter 返回TakeUpdateModel取出的更新写入后文档应满足的过滤条件，需在MarkSaved之前调用
+	AppliedFilter() bson.D
+}
+
+// isApplied 查询文档是否满足更新写入后的状态，用于无法从写入结果判断更新是否生效时
+// 实现了AppliedFilterer的文档按主键和自增后的版本号查询，其余文档只检查主键是否存在
+func isApplied(ctx context.Context, collection *mongo.Collection, doc Document) (bool, error) {
+	filter := bson.D{{Key: "_id", Value: doc.PrimaryKey()}}
+	if f, ok := doc.(AppliedFilterer); ok {
+		filter = f.AppliedFilter()
+	}
+	projection := options.FindOne().SetProjection(bson.D{{Key: "_id", Value: 1}})
+	err := collection.FindOne(ctx, filter, projection).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DirtySnapshot 生成类型TakeDirty取出的脏状态快照
+type DirtySnapshot interface {
+	// IsEmpty 快照中是否没有任何变更
+	IsEmpty() bool
+}
@@ -0,0 +1,69 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrVersionConflict 乐观锁版本冲突，可用errors.Is判断
+var ErrVersionConflict = errors.New("orm: version conflict")
+
+// VersionConflictError 保存时文档版本与内存中不一致（已被其他进程修改或删除）
+type VersionConflictError struct {
+	Collection string      // 集合名
+	ID         interface{} // 文档主键
+	Version    int64       // 保存时内存中的版本号
+}
+
+func (e *VersionConflictError) Error() string {
+	if e.Version == 0 {
+		return fmt.Sprintf("orm: version conflict on %s/%v", e.Collection, e.ID)
+	}
+	return fmt.Sprintf("orm: version conflict on %s/%v (version %d)", e.Collection, e.ID, e.Version)
+}
+
+// Unwrap 使errors.Is(err, ErrVersionConflict)成立
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
+// ErrPartialDocument 对部分加载的文档执行了需要完整数据的操作
+var ErrPartialDocument = errors.New("orm: partially loaded document")
+
+// UnloadedFieldError 部分加载的文档生成的更新依赖未加载的字段
+type UnloadedFieldError struct {
+	Path string // 字段在文档中的路径
+}
+
+func (e *UnloadedFieldError) Error() string {
+	return fmt.Sprintf("orm: update of %s depends on unloaded data", e.Path)
+}
+
+// Unwrap 使errors.Is(err, ErrPartialDocument)成立
+func (e *UnloadedFieldError) Unwrap() error {
+	return ErrPartialDocument
+}
+
+// ErrInvalidPageToken 分页令牌无法解析或与查询的排序方式不一致
+var ErrInvalidPageToken = errors.New("orm: invalid page token")
+
+// ErrUnsafeMapKey 字典键为空、含有.或以$开头，无法用作字段路径
+var ErrUnsafeMapKey = errors.New("orm: map key cannot be used in a field path")
+
+// ErrIdentityConflict 同一工作单元中出现了同一文档的两个不同实例
+var ErrIdentityConflict = errors.New("orm: identity conflict")
+
+// IdentityConflictError 登记的实体与工作单元中已跟踪的实例主键相同但不是同一个对象
+type IdentityConflictError struct {
+	Collection string      // 集合名
+	ID         interface{} // 文档主键
+}
+
+func (e *IdentityConflictError) Error() string {
+	return fmt.Sprintf("orm: %s/%v is already tracked by another instance", e.Collection, e.ID)
+}
+
+// Unwrap 使errors.Is(err, ErrIdentityConflict)成立
+func (e *IdentityConflictError) Unwrap() error {
+	return ErrIdentityConflict
+}
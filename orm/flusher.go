@@ -0,0 +1,247 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// FlushFailureHook 实体重试耗尽后仍写入失败时调用，实体保持脏状态，下一轮刷新会再次尝试
+type FlushFailureHook func(collection *mongo.Collection, doc Document, err error)
+
+// Flusher 延迟写回调度器，用于长期驻留内存的实体（如游戏玩家、会话对象）
+// 定时以及停止时收集所有脏实体，以有限并发增量写回，失败时按指数退避重试
+// 写入前取出实体的脏状态，刷新期间的新修改会在下一轮写回；
+// 取出脏状态时读取实体，调用方仍需保证取出与修改不会并发执行
+type Flusher struct {
+	interval    time.Duration
+	concurrency int
+	maxRetries  int
+	backoff     time.Duration
+	onFailure   FlushFailureHook
+
+	mu       sync.Mutex
+	entries  map[Document]*mongo.Collection
+	started  bool       // Start或Stop已调用，由mu保护
+	flushMu  sync.Mutex // 保证同一时刻只有一轮刷新
+	stopCh   chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFlusher 创建按interval定时刷新的调度器，默认并发4，失败重试3次，初始退避100ms
+func NewFlusher(interval time.Duration) *Flusher {
+	return &Flusher{
+		interval:    interval,
+		concurrency: 4,
+		maxRetries:  3,
+		backoff:     100 * time.Millisecond,
+		entries:     make(map[Document]*mongo.Collection),
+	}
+}
+
+// SetConcurrency 设置一轮刷新中同时写入的最大实体数
+func (f *Flusher) SetConcurrency(concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	f.concurrency = concurrency
+}
+
+// SetRetry 设置单个实体写入失败后的重试次数和初始退避时间，退避时间每次翻倍
+func (f *Flusher) SetRetry(maxRetries int, backoff time.Duration) {
+	f.maxRetries = maxRetries
+	f.backoff = backoff
+}
+
+// OnFailure 设置写入失败回调
+func (f *Flusher) OnFailure(hook FlushFailureHook) {
+	f.onFailure = hook
+}
+
+// Register 登记需要定期写回的实体
+func (f *Flusher) Register(collection *mongo.Collection, doc Document) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[doc] = collection
+}
+
+// Unregister 取消登记，通常在实体离开内存前先Flush再调用
+func (f *Flusher) Unregister(doc Document) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.entries, doc)
+}
+
+// Start 启动后台定时刷新，重复调用或Stop之后调用无效
+func (f *Flusher) Start() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.started {
+		return
+	}
+	f.started = true
+	f.stopCh = make(chan struct{})
+	f.stopped = make(chan struct{})
+	go f.loop()
+}
+
+func (f *Flusher) loop() {
+	defer close(f.stopped)
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// 失败已通过回调上报，这里不需要再处理
+			_ = f.Flush(context.Background())
+		case <-f.stopCh:
+			return
+		}
+	}
+}
+
+// Stop 停止定时刷新并做最后一次刷新，返回最后一次刷新的错误
+func (f *Flusher) Stop(ctx context.Context) error {
+	f.stopOnce.Do(func() {
+		f.mu.Lock()
+		f.started = true
+		stopCh, stopped := f.stopCh, f.stopped
+		f.mu.Unlock()
+		if stopCh != nil {
+			close(stopCh)
+			<-stopped
+		}
+	})
+	return f.Flush(ctx)
+}
+
+// flushEntry 一个待写回的实体
+type flushEntry struct {
+	collection *mongo.Collection
+	doc        Document
+}
+
+// dirtyEntries 收集当前所有脏实体
+func (f *Flusher) dirtyEntries() []flushEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var entries []flushEntry
+	for doc, collection := range f.entries {
+		if doc.IsDirty() {
+			entries = append(entries, flushEntry{collection: collection, doc: doc})
+		}
+	}
+	return entries
+}
+
+// Flush 立即写回所有脏实体，返回所有失败实体的错误
+func (f *Flusher) Flush(ctx context.Context) error {
+	f.flushMu.Lock()
+	defer f.flushMu.Unlock()
+
+	entries := f.dirtyEntries()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, f.concurrency)
+	for _, entry := range entries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(entry flushEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := f.flushOne(ctx, entry); err != nil {
+				if f.onFailure != nil {
+					f.onFailure(entry.collection, entry.doc, err)
+				}
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(entry)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// flushOne 写回单个实体，只重试驱动标记为可重试的错误，按指数退避
+// 写入前取出脏状态，刷新期间对实体的新修改不会丢失，最终失败时把取出的脏状态合并回实体
+func (f *Flusher) flushOne(ctx context.Context, entry flushEntry) error {
+	model, snapshot, err := entry.doc.TakeUpdateModel()
+	if err != nil || model == nil {
+		return err
+	}
+
+	backoff := f.backoff
+	for attempt := 0; ; attempt++ {
+		var result *mongo.UpdateResult
+		result, err = entry.collection.UpdateOne(ctx, model.Filter, model.Update)
+		if err == nil {
+			if result.MatchedCount == 0 {
+				// 之前失败的尝试可能已经生效，版本号随之自增，重试时不再匹配
+				applied := false
+				if attempt > 0 {
+					if applied, err = isApplied(ctx, entry.collection, entry.doc); err != nil {
+						break
+					}
+				}
+				if !applied {
+					entry.doc.RestoreSnapshot(snapshot)
+					if _, ok := entry.doc.(AppliedFilterer); !ok {
+						// 没有版本号的文档只按主键匹配，未匹配说明已被删除，与生成的Save一样返回mongo.ErrNoDocuments
+						return fmt.Errorf("orm: flush %s/%v: %w", entry.collection.Name(), entry.doc.PrimaryKey(), mongo.ErrNoDocuments)
+					}
+					return &VersionConflictError{Collection: entry.collection.Name(), ID: entry.doc.PrimaryKey()}
+				}
+			}
+			entry.doc.MarkSaved()
+			return nil
+		}
+		if attempt >= f.maxRetries || !retryable(err, entry.doc, model.Update) {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			entry.doc.RestoreSnapshot(snapshot)
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+	entry.doc.RestoreSnapshot(snapshot)
+	return fmt.Errorf("orm: flush %s/%v: %w", entry.collection.Name(), entry.doc.PrimaryKey(), err)
+}
+
+// retryable 判断写入失败后能否重发更新，只重试驱动标记为RetryableWriteError的错误
+// 失败的写入可能已经生效：带版本号的文档重发时不会再匹配，没有版本号的文档重发$inc会重复累加，不重试
+func retryable(err error, doc Document, update interface{}) bool {
+	var labeled mongo.LabeledError
+	if !errors.As(err, &labeled) || !labeled.HasErrorLabel("RetryableWriteError") {
+		return false
+	}
+	if _, ok := doc.(AppliedFilterer); ok {
+		return true
+	}
+	raw, ok := update.(bson.Raw)
+	if !ok {
+		data, err := bson.Marshal(update)
+		if err != nil {
+			return false
+		}
+		raw = data
+	}
+	_, err = raw.LookupErr("$inc")
+	return err != nil
+}
@@ -0,0 +1,37 @@
+package orm
+
+import "context"
+
+// 生命周期钩子，生成类型在同一个包中实现这些接口后由生成的仓储调用
+// Before钩子返回错误时中止操作；After钩子在写入成功后调用，返回的错误原样返回给调用方，已完成的写入不会撤销
+
+// BeforeInserter Insert写入前调用，可以补全字段或拒绝插入
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// AfterInserter Insert写入成功后调用
+type AfterInserter interface {
+	AfterInsert(ctx context.Context) error
+}
+
+// BeforeUpdater Save构建增量更新前调用，dirtyFields为脏字段的索引（<Message><Field>FieldIndex）
+// 可以返回错误拒绝本次修改，也可以调用Setter追加修改，追加的修改随本次更新写入
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context, dirtyFields []int) error
+}
+
+// AfterUpdater Save写入成功后调用，没有变更而未访问数据库时不调用
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+// BeforeDeleter Delete前调用，仓储先按主键加载实体，文档不存在时不调用
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterLoader 从数据库解码后调用，用于建立派生状态；返回错误时加载失败
+type AfterLoader interface {
+	AfterLoad(ctx context.Context) error
+}
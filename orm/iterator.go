@@ -0,0 +1,93 @@
+package orm
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Unmarshaler 可从BSON文档解码的生成类型
+type Unmarshaler interface {
+	UnmarshalBSON(data []byte) error
+}
+
+// ProjectedUnmarshaler 声明了schema_version的生成类型实现，解码投影或聚合的结果时不执行结构升级
+type ProjectedUnmarshaler interface {
+	UnmarshalProjectedBSON(data []byte) error
+}
+
+// Iterator 流式遍历查询结果，每个文档通过UnmarshalBSON解码为新建的实体，实现了AfterLoader时随后调用AfterLoad
+// 解码后的实体没有脏状态；用完后需调用Close释放游标
+type Iterator[T Unmarshaler] struct {
+	cursor  *mongo.Cursor
+	newFn   func() T
+	current T
+	err     error
+	// 结果来自聚合管道，可能缺少字段，实现了ProjectedUnmarshaler时按投影结果解码
+	projected bool
+}
+
+// NewIterator 用游标和实体构造函数创建迭代器，生成代码使用
+func NewIterator[T Unmarshaler](cursor *mongo.Cursor, newFn func() T) *Iterator[T] {
+	return &Iterator[T]{cursor: cursor, newFn: newFn}
+}
+
+// Next 读取并解码下一个文档，没有更多文档或出错时返回false，错误由Err返回
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	if it.err != nil || !it.cursor.Next(ctx) {
+		return false
+	}
+	x := it.newFn()
+	var err error
+	if u, ok := interface{}(x).(ProjectedUnmarshaler); ok && it.projected {
+		err = u.UnmarshalProjectedBSON(it.cursor.Current)
+	} else {
+		err = x.UnmarshalBSON(it.cursor.Current)
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if h, ok := interface{}(x).(AfterLoader); ok {
+		if err := h.AfterLoad(ctx); err != nil {
+			it.err = err
+			return false
+		}
+	}
+	it.current = x
+	return true
+}
+
+// Value 返回当前实体
+func (it *Iterator[T]) Value() T {
+	return it.current
+}
+
+// Raw 返回当前文档的原始数据，只在下一次调用Next之前有效
+func (it *Iterator[T]) Raw() bson.Raw {
+	return it.cursor.Current
+}
+
+// Err 返回遍历过程中的解码或游标错误
+func (it *Iterator[T]) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.cursor.Err()
+}
+
+// Close 关闭游标
+func (it *Iterator[T]) Close(ctx context.Context) error {
+	return it.cursor.Close(ctx)
+}
+
+// All 读取剩余的所有实体并关闭游标
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	defer it.Close(ctx)
+	var items []T
+	for it.Next(ctx) {
+		items = append(items, it.Value())
+	}
+	return items, it.Err()
+}
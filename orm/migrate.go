@@ -0,0 +1,73 @@
+package orm
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationProgress 批量迁移的进度
+type MigrationProgress struct {
+	Scanned   int64 // 已检查的文档数
+	Migrated  int64 // 已改写的文档数
+	Conflicts int64 // 加载后被并发修改或删除而未改写的文档数，可再次迁移
+}
+
+// Migrate 遍历集合中的全部文档，加载时需要迁移的文档带有脏标记，逐个按增量更新改写
+// 不经过仓储的生命周期钩子和软删除范围；progress非nil时每处理完一个游标批次调用一次
+// 生成的Repository.Migrate调用
+func Migrate[T interface {
+	Document
+	Unmarshaler
+}](ctx context.Context, collection *mongo.Collection, newDoc func() T, progress func(MigrationProgress), opts ...*options.FindOptions) (MigrationProgress, error) {
+	var p MigrationProgress
+	cursor, err := collection.Find(ctx, bson.D{}, opts...)
+	if err != nil {
+		return p, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		doc := newDoc()
+		if err := doc.UnmarshalBSON(cursor.Current); err != nil {
+			return p, err
+		}
+		p.Scanned++
+		model, _, err := doc.TakeUpdateModel()
+		if err != nil {
+			return p, err
+		}
+		if model != nil {
+			result, err := collection.UpdateOne(ctx, withoutSoftDelete(model.Filter), model.Update)
+			if err != nil {
+				return p, err
+			}
+			if result.MatchedCount == 0 {
+				p.Conflicts++
+			} else {
+				p.Migrated++
+			}
+		}
+		if progress != nil && cursor.RemainingBatchLength() == 0 {
+			progress(p)
+		}
+	}
+	return p, cursor.Err()
+}
+
+// withoutSoftDelete 去掉增量更新过滤条件中排除已软删除文档的条件，迁移同样改写已删除的文档
+func withoutSoftDelete(filter interface{}) interface{} {
+	d, ok := filter.(bson.D)
+	if !ok {
+		return filter
+	}
+	kept := make(bson.D, 0, len(d))
+	for _, e := range d {
+		if e.Key != SoftDeleteField {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,112 @@
+package orm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Pather 提供文档中点分路径的字段描述符，生成的字段路径类型都实现了它
+type Pather interface {
+	Path() string
+}
+
+// Sort 键集分页的排序字段，_id总是作为第二排序键保证顺序稳定
+type Sort struct {
+	Path       string // 排序字段在文档中的路径
+	Descending bool   // 是否降序
+}
+
+// Asc 按字段升序
+func Asc(field Pather) Sort {
+	return Sort{Path: field.Path()}
+}
+
+// Desc 按字段降序
+func Desc(field Pather) Sort {
+	return Sort{Path: field.Path(), Descending: true}
+}
+
+// D 渲染为排序文档，排序字段之后追加_id
+func (s Sort) D() bson.D {
+	dir := 1
+	if s.Descending {
+		dir = -1
+	}
+	if s.Path == "" || s.Path == "_id" {
+		return bson.D{{Key: "_id", Value: dir}}
+	}
+	return bson.D{{Key: s.Path, Value: dir}, {Key: "_id", Value: dir}}
+}
+
+// Page 一页查询结果，NextToken为空表示没有更多数据
+type Page[T any] struct {
+	Items     []T
+	NextToken string
+}
+
+// pageToken 页令牌的内容，记录上一页最后一个文档的排序值和主键
+// 同时记录排序方式，避免令牌被用于不同的排序
+type pageToken struct {
+	Path       string        `bson:"p"`
+	Descending bool          `bson:"d"`
+	Value      bson.RawValue `bson:"v"`
+	ID         bson.RawValue `bson:"id"`
+}
+
+// NextPageToken 根据一页中最后一个文档生成下一页的令牌，令牌为URL安全的base64字符串
+// 排序字段缺失时按null处理，排序字段应在所有文档中存在
+func NextPageToken(sort Sort, last bson.Raw) (string, error) {
+	id, err := last.LookupErr("_id")
+	if err != nil {
+		return "", fmt.Errorf("orm: page token: %w", err)
+	}
+	token := pageToken{Path: sort.Path, Descending: sort.Descending, ID: id}
+	if sort.Path != "" && sort.Path != "_id" {
+		value, err := last.LookupErr(strings.Split(sort.Path, ".")...)
+		if err != nil {
+			value = bson.RawValue{Type: bson.TypeNull}
+		}
+		token.Value = value
+	}
+	data, err := bson.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// KeysetFilter 返回从令牌位置之后开始的过滤条件，令牌为空时返回匹配全部的条件
+// 令牌无法解析或与排序方式不一致时返回ErrInvalidPageToken
+func KeysetFilter(sort Sort, token string) (Filter, error) {
+	if token == "" {
+		return Filter{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Filter{}, ErrInvalidPageToken
+	}
+	var t pageToken
+	if err := bson.Unmarshal(data, &t); err != nil || t.ID.Type == 0 {
+		return Filter{}, ErrInvalidPageToken
+	}
+	if t.Path != sort.Path || t.Descending != sort.Descending {
+		return Filter{}, ErrInvalidPageToken
+	}
+	op := "$gt"
+	if sort.Descending {
+		op = "$lt"
+	}
+	if sort.Path == "" || sort.Path == "_id" {
+		return condition("_id", op, t.ID), nil
+	}
+	return Or(
+		condition(sort.Path, op, t.Value),
+		Filter{elements: bson.D{
+			{Key: sort.Path, Value: t.Value},
+			{Key: "_id", Value: bson.D{{Key: op, Value: t.ID}}},
+		}},
+	), nil
+}
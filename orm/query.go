@@ -0,0 +1,314 @@
+package orm
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Filter 查询条件，通过D渲染为MongoDB过滤文档
+// 由字段描述符的方法和And/Or组合得到，零值表示匹配全部文档
+type Filter struct {
+	elements bson.D
+	err      error // 构建条件时的错误，组合时保留第一个
+}
+
+// D 渲染为bson.D，可直接传给Find、UpdateMany等方法
+// 条件带有错误时渲染为不匹配任何文档的条件，调用方应先检查Err
+func (f Filter) D() bson.D {
+	if f.err != nil {
+		return bson.D{{Key: "$expr", Value: false}}
+	}
+	if f.elements == nil {
+		return bson.D{}
+	}
+	return f.elements
+}
+
+// Err 返回构建条件时的错误，如字典键无法用作字段路径；生成的仓储在查询前检查
+func (f Filter) Err() error {
+	return f.err
+}
+
+// firstErr 返回多个条件中第一个错误
+func firstErr(filters []Filter) error {
+	for _, f := range filters {
+		if f.err != nil {
+			return f.err
+		}
+	}
+	return nil
+}
+
+// And 所有条件同时满足，渲染为$and；忽略空条件，只有一个条件时直接返回该条件
+func And(filters ...Filter) Filter {
+	nonEmpty := make([]Filter, 0, len(filters))
+	for _, f := range filters {
+		if len(f.elements) > 0 || f.err != nil {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	return combine("$and", nonEmpty)
+}
+
+// Or 任一条件满足，渲染为$or；只有一个条件时直接返回该条件
+func Or(filters ...Filter) Filter {
+	return combine("$or", filters)
+}
+
+// Nor 所有条件都不满足，渲染为$nor
+func Nor(filters ...Filter) Filter {
+	items := make(bson.A, 0, len(filters))
+	for _, f := range filters {
+		items = append(items, f.D())
+	}
+	return Filter{elements: bson.D{{Key: "$nor", Value: items}}, err: firstErr(filters)}
+}
+
+func combine(op string, filters []Filter) Filter {
+	switch len(filters) {
+	case 0:
+		return Filter{}
+	case 1:
+		return filters[0]
+	}
+	items := make(bson.A, 0, len(filters))
+	for _, f := range filters {
+		items = append(items, f.D())
+	}
+	return Filter{elements: bson.D{{Key: op, Value: items}}, err: firstErr(filters)}
+}
+
+// condition 构建单个字段条件
+// 路径为空时表示$elemMatch中的数组元素本身，只输出操作符
+func condition(path, op string, value interface{}) Filter {
+	if path == "" {
+		return Filter{elements: bson.D{{Key: op, Value: value}}}
+	}
+	if op == "$eq" {
+		return Filter{elements: bson.D{{Key: path, Value: value}}}
+	}
+	return Filter{elements: bson.D{{Key: path, Value: bson.D{{Key: op, Value: value}}}}}
+}
+
+// Field 类型为T的字段路径，比较方法只接受T类型的值
+type Field[T any] struct {
+	path string
+	err  error // 路径无效时的错误，由该字段构建的条件都带有它
+}
+
+// NewField 创建字段路径，生成代码使用
+func NewField[T any](path string) Field[T] {
+	return Field[T]{path: path}
+}
+
+// Path 返回BSON文档中的点分路径
+func (f Field[T]) Path() string {
+	return f.path
+}
+
+// Err 返回路径无效时的错误，如字典键无法用作字段路径
+func (f Field[T]) Err() error {
+	return f.err
+}
+
+// filter 构建该字段的条件并带上路径的错误
+func (f Field[T]) filter(op string, value interface{}) Filter {
+	filter := condition(f.path, op, value)
+	filter.err = f.err
+	return filter
+}
+
+// Eq 等于
+func (f Field[T]) Eq(v T) Filter {
+	return f.filter("$eq", v)
+}
+
+// Ne 不等于
+func (f Field[T]) Ne(v T) Filter {
+	return f.filter("$ne", v)
+}
+
+// Gt 大于
+func (f Field[T]) Gt(v T) Filter {
+	return f.filter("$gt", v)
+}
+
+// Gte 大于等于
+func (f Field[T]) Gte(v T) Filter {
+	return f.filter("$gte", v)
+}
+
+// Lt 小于
+func (f Field[T]) Lt(v T) Filter {
+	return f.filter("$lt", v)
+}
+
+// Lte 小于等于
+func (f Field[T]) Lte(v T) Filter {
+	return f.filter("$lte", v)
+}
+
+// In 等于其中任一值
+func (f Field[T]) In(values ...T) Filter {
+	return f.filter("$in", toArray(values))
+}
+
+// Nin 不等于其中任何值
+func (f Field[T]) Nin(values ...T) Filter {
+	return f.filter("$nin", toArray(values))
+}
+
+// Exists 字段是否存在
+func (f Field[T]) Exists(exists bool) Filter {
+	return f.filter("$exists", exists)
+}
+
+// StringField 字符串字段路径，额外支持正则匹配
+type StringField struct {
+	Field[string]
+}
+
+// NewStringField 创建字符串字段路径，生成代码使用
+func NewStringField(path string) StringField {
+	return StringField{Field: Field[string]{path: path}}
+}
+
+// Regex 正则匹配，options为MongoDB正则选项，如"i"表示忽略大小写
+func (f StringField) Regex(pattern, options string) Filter {
+	return f.filter("$regex", primitive.Regex{Pattern: pattern, Options: options})
+}
+
+// ListField 元素类型为E的数组字段路径
+type ListField[E any] struct {
+	path string
+}
+
+// NewListField 创建数组字段路径，生成代码使用
+func NewListField[E any](path string) ListField[E] {
+	return ListField[E]{path: path}
+}
+
+// Path 返回BSON文档中的点分路径
+func (f ListField[E]) Path() string {
+	return f.path
+}
+
+// Elem 返回表示数组元素本身的字段，用于构建ElemMatch中的条件
+func (f ListField[E]) Elem() Field[E] {
+	return Field[E]{}
+}
+
+// Contains 数组包含指定元素
+func (f ListField[E]) Contains(v E) Filter {
+	return condition(f.path, "$eq", v)
+}
+
+// All 数组包含所有指定元素
+func (f ListField[E]) All(values ...E) Filter {
+	return condition(f.path, "$all", toArray(values))
+}
+
+// Size 数组长度等于n
+func (f ListField[E]) Size(n int) Filter {
+	return condition(f.path, "$size", n)
+}
+
+// ElemMatch 至少有一个元素同时满足所有条件
+// 标量数组的条件使用Elem()构建，消息数组的条件使用以空前缀创建的字段路径构建
+func (f ListField[E]) ElemMatch(filters ...Filter) Filter {
+	match := bson.D{}
+	for _, item := range filters {
+		match = append(match, item.elements...)
+	}
+	filter := condition(f.path, "$elemMatch", match)
+	filter.err = firstErr(filters)
+	return filter
+}
+
+// Exists 字段是否存在
+func (f ListField[E]) Exists(exists bool) Filter {
+	return condition(f.path, "$exists", exists)
+}
+
+// MapField 键类型为K、值类型为V的字典字段路径
+type MapField[K comparable, V any] struct {
+	path string
+}
+
+// NewMapField 创建字典字段路径，生成代码使用
+func NewMapField[K comparable, V any](path string) MapField[K, V] {
+	return MapField[K, V]{path: path}
+}
+
+// Path 返回BSON文档中的点分路径
+func (f MapField[K, V]) Path() string {
+	return f.path
+}
+
+// Key 返回字典中指定键的值路径；键为空、含有.或以$开头时无法用点分路径表示，
+// 返回的字段及由它构建的条件带有ErrUnsafeMapKey，查询时返回该错误
+func (f MapField[K, V]) Key(key K) Field[V] {
+	s := fmt.Sprint(key)
+	field := Field[V]{path: f.path + "." + s}
+	if !IsSafeMapKey(s) {
+		field.err = fmt.Errorf("%w: %q in %s", ErrUnsafeMapKey, s, f.path)
+	}
+	return field
+}
+
+// IsSafeMapKey 字典键能否作为点分路径的一段：不为空、不含.且不以$开头
+// 生成代码遇到其他键时整体写入字典，避免键被解释为嵌套路径或操作符
+func IsSafeMapKey(key string) bool {
+	return key != "" && !strings.Contains(key, ".") && !strings.HasPrefix(key, "$")
+}
+
+// SafeMapKeys 字典的所有键是否都满足IsSafeMapKey
+func SafeMapKeys[V any](m map[string]V) bool {
+	for key := range m {
+		if !IsSafeMapKey(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// Exists 字段是否存在
+func (f MapField[K, V]) Exists(exists bool) Filter {
+	return condition(f.path, "$exists", exists)
+}
+
+// MessageField 嵌套消息字段路径，生成的字段路径类型内嵌它
+type MessageField struct {
+	path string
+}
+
+// NewMessageField 创建嵌套消息字段路径，生成代码使用
+func NewMessageField(path string) MessageField {
+	return MessageField{path: path}
+}
+
+// Path 返回BSON文档中的点分路径
+func (f MessageField) Path() string {
+	return f.path
+}
+
+// Exists 字段是否存在
+func (f MessageField) Exists(exists bool) Filter {
+	return condition(f.path, "$exists", exists)
+}
+
+// IsNull 字段为null或不存在
+func (f MessageField) IsNull() Filter {
+	return condition(f.path, "$eq", nil)
+}
+
+func toArray[T any](values []T) bson.A {
+	items := make(bson.A, 0, len(values))
+	for _, v := range values {
+		items = append(items, v)
+	}
+	return items
+}
@@ -0,0 +1,28 @@
+package orm
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// namespaceNotFound collMod作用于不存在的集合时返回的错误码
+const namespaceNotFound = 26
+
+// ApplySchemaValidator 用collMod把$jsonSchema校验器应用到集合，集合不存在时带校验器创建
+// 使用服务端默认的validationLevel(strict)和validationAction(error)，生成的Apply<Message>SchemaValidator调用
+func ApplySchemaValidator(ctx context.Context, db *mongo.Database, collection string, schema bson.D) error {
+	validator := bson.D{{Key: "$jsonSchema", Value: schema}}
+	err := db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection},
+		{Key: "validator", Value: validator},
+	}).Err()
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == namespaceNotFound {
+		return db.CreateCollection(ctx, collection, options.CreateCollection().SetValidator(validator))
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+package orm
+
+// SoftDeleteField 软删除时间戳在文档中的键名，不对应消息字段
+const SoftDeleteField = "deleted_at"
+
+// DeletedScope 启用软删除的仓储查询时如何对待已删除的文档
+type DeletedScope int
+
+const (
+	// ExcludeDeleted 排除已删除的文档，默认行为
+	ExcludeDeleted DeletedScope = iota
+	// IncludeDeleted 同时返回已删除和未删除的文档
+	IncludeDeleted
+	// OnlyDeleted 只返回已删除的文档
+	OnlyDeleted
+)
+
+// Filter 返回该范围对应的过滤条件，IncludeDeleted返回匹配全部的空条件
+func (s DeletedScope) Filter() Filter {
+	switch s {
+	case ExcludeDeleted:
+		// deleted_at为null或不存在
+		return condition(SoftDeleteField, "$eq", nil)
+	case OnlyDeleted:
+		return condition(SoftDeleteField, "$ne", nil)
+	}
+	return Filter{}
+}
@@ -0,0 +1,279 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UnitOfWork 工作单元，跟踪一次请求中加载的实体，Commit时按集合用一次BulkWrite写回所有脏实体
+// 同时维护标识映射，保证同一文档在一个工作单元内只对应一个对象
+// 非并发安全，应在单个请求内使用
+type UnitOfWork struct {
+	transactional bool
+	collections   []*mongo.Collection // 按首次注册顺序写入
+	documents     map[*mongo.Collection][]Document
+	registered    map[Document]bool
+	identities    map[identityKey]Document // 集合+主键 -> 已跟踪的实例
+}
+
+// identityKey 标识映射的键
+type identityKey struct {
+	namespace string // 数据库名.集合名
+	id        interface{}
+}
+
+func newIdentityKey(collection *mongo.Collection, id interface{}) identityKey {
+	// []byte不能作为map键，按内容转换为字符串
+	if b, ok := id.([]byte); ok {
+		id = string(b)
+	}
+	return identityKey{
+		namespace: collection.Database().Name() + "." + collection.Name(),
+		id:        id,
+	}
+}
+
+// NewUnitOfWork 创建工作单元
+func NewUnitOfWork() *UnitOfWork {
+	return &UnitOfWork{
+		documents:  make(map[*mongo.Collection][]Document),
+		registered: make(map[Document]bool),
+		identities: make(map[identityKey]Document),
+	}
+}
+
+// SetTransactional 设置Commit是否在一个事务中执行所有BulkWrite，事务需要副本集或分片集群
+func (u *UnitOfWork) SetTransactional(transactional bool) {
+	u.transactional = transactional
+}
+
+// Register 登记实体及其所属集合，重复登记同一实体会被忽略
+// 同一集合中已有相同主键的其他实例时返回*IdentityConflictError，避免两个对象各自修改同一文档
+func (u *UnitOfWork) Register(collection *mongo.Collection, doc Document) error {
+	if doc == nil || u.registered[doc] {
+		return nil
+	}
+	key := newIdentityKey(collection, doc.PrimaryKey())
+	if tracked, ok := u.identities[key]; ok && tracked != doc {
+		return &IdentityConflictError{Collection: collection.Name(), ID: doc.PrimaryKey()}
+	}
+	if _, ok := u.documents[collection]; !ok {
+		u.collections = append(u.collections, collection)
+	}
+	u.documents[collection] = append(u.documents[collection], doc)
+	u.registered[doc] = true
+	u.identities[key] = doc
+	return nil
+}
+
+// Lookup 按集合和主键查找已跟踪的实例
+func (u *UnitOfWork) Lookup(collection *mongo.Collection, id interface{}) (Document, bool) {
+	doc, ok := u.identities[newIdentityKey(collection, id)]
+	return doc, ok
+}
+
+// Evict 停止跟踪指定文档，用于文档被删除后避免Commit再写回
+func (u *UnitOfWork) Evict(collection *mongo.Collection, id interface{}) {
+	key := newIdentityKey(collection, id)
+	doc, ok := u.identities[key]
+	if !ok {
+		return
+	}
+	delete(u.identities, key)
+	delete(u.registered, doc)
+	for c, docs := range u.documents {
+		for i, d := range docs {
+			if d == doc {
+				u.documents[c] = append(docs[:i], docs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// bulkBatch 单个集合的一批增量更新
+type bulkBatch struct {
+	collection *mongo.Collection
+	documents  []Document
+	snapshots  []DirtySnapshot // 与documents一一对应，写入失败时合并回实体
+	models     []mongo.WriteModel
+}
+
+// write 提交一批更新，返回匹配的文档数；匹配数少于模型数说明有文档被删除或版本冲突
+// 出错时返回出错前已执行部分的匹配数
+func (b *bulkBatch) write(ctx context.Context) (int64, error) {
+	result, err := b.collection.BulkWrite(ctx, b.models)
+	if result == nil {
+		return 0, err
+	}
+	return result.MatchedCount, err
+}
+
+// conflictError 批量写入部分未匹配时返回的错误
+func (b *bulkBatch) conflictError(matched int64) error {
+	return fmt.Errorf("orm: %s matched %d of %d documents: %w",
+		b.collection.Name(), matched, len(b.models), ErrVersionConflict)
+}
+
+// settle 非事务模式下无法从写入结果判断哪些文档已经写入时，逐个查询下标在[from, to)的文档
+// 已写入的文档同步版本号，未写入的恢复脏状态；查询出错时尚未确认的文档恢复脏状态
+func (b *bulkBatch) settle(ctx context.Context, from, to int) error {
+	for i := from; i < to; i++ {
+		ok, err := isApplied(ctx, b.collection, b.documents[i])
+		if err != nil {
+			b.restoreRange(i, to)
+			return err
+		}
+		if ok {
+			b.documents[i].MarkSaved()
+		} else {
+			b.documents[i].RestoreSnapshot(b.snapshots[i])
+		}
+	}
+	return nil
+}
+
+// fail 非事务模式下批量写入出错时，同步已经执行的模型，其余恢复脏状态
+// 有序BulkWrite在第一个写错误处停止，之前的模型已经执行：匹配数与之相等时全部写入，否则逐个查询；
+// 没有写错误下标时（如网络错误、写关注错误）无法确定执行到哪里，逐个查询
+func (b *bulkBatch) fail(ctx context.Context, matched int64, err error) error {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || len(bulkErr.WriteErrors) == 0 {
+		if settleErr := b.settle(ctx, 0, len(b.documents)); settleErr != nil {
+			return errors.Join(err, settleErr)
+		}
+		return err
+	}
+	first := len(b.documents)
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Index < first {
+			first = writeErr.Index
+		}
+	}
+	b.restoreRange(first, len(b.documents))
+	if matched == int64(first) {
+		b.markSavedRange(0, first)
+	} else if settleErr := b.settle(ctx, 0, first); settleErr != nil {
+		return errors.Join(err, settleErr)
+	}
+	return err
+}
+
+func (b *bulkBatch) markSaved() {
+	b.markSavedRange(0, len(b.documents))
+}
+
+func (b *bulkBatch) markSavedRange(from, to int) {
+	for i := from; i < to; i++ {
+		b.documents[i].MarkSaved()
+	}
+}
+
+func (b *bulkBatch) restore() {
+	b.restoreRange(0, len(b.documents))
+}
+
+func (b *bulkBatch) restoreRange(from, to int) {
+	for i := from; i < to; i++ {
+		b.documents[i].RestoreSnapshot(b.snapshots[i])
+	}
+}
+
+// collectBatches 遍历所有已登记实体，按集合取出脏实体的更新模型
+func (u *UnitOfWork) collectBatches() ([]*bulkBatch, error) {
+	var batches []*bulkBatch
+	for _, collection := range u.collections {
+		batch := &bulkBatch{collection: collection}
+		for _, doc := range u.documents[collection] {
+			if !doc.IsDirty() {
+				continue
+			}
+			model, snapshot, err := doc.TakeUpdateModel()
+			if err != nil {
+				batch.restore()
+				restoreBatches(batches)
+				return nil, err
+			}
+			if model == nil {
+				continue
+			}
+			batch.documents = append(batch.documents, doc)
+			batch.snapshots = append(batch.snapshots, snapshot)
+			batch.models = append(batch.models, model)
+		}
+		if len(batch.models) > 0 {
+			batches = append(batches, batch)
+		}
+	}
+	return batches, nil
+}
+
+func restoreBatches(batches []*bulkBatch) {
+	for _, batch := range batches {
+		batch.restore()
+	}
+}
+
+// Commit 将所有脏实体写回数据库，每个集合一次BulkWrite
+// 写入前取出各实体的脏状态，提交期间对实体的新修改保留到下次提交
+// 非事务模式下按集合依次写入，失败的集合中已写入的文档同步版本号，未写入的文档及之后
+// 未写入的集合中的实体恢复脏状态；事务模式下任一集合失败则全部恢复脏状态
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	batches, err := u.collectBatches()
+	if err != nil {
+		return err
+	}
+	if len(batches) == 0 {
+		return nil
+	}
+
+	if !u.transactional {
+		for i, batch := range batches {
+			matched, err := batch.write(ctx)
+			if err != nil {
+				restoreBatches(batches[i+1:])
+				return batch.fail(ctx, matched, err)
+			}
+			if matched != int64(len(batch.models)) {
+				restoreBatches(batches[i+1:])
+				if err := batch.settle(ctx, 0, len(batch.documents)); err != nil {
+					return err
+				}
+				return batch.conflictError(matched)
+			}
+			batch.markSaved()
+		}
+		return nil
+	}
+
+	session, err := batches[0].collection.Database().Client().StartSession()
+	if err != nil {
+		restoreBatches(batches)
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		for _, batch := range batches {
+			matched, err := batch.write(sessCtx)
+			if err != nil {
+				return nil, err
+			}
+			if matched != int64(len(batch.models)) {
+				return nil, batch.conflictError(matched)
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		restoreBatches(batches)
+		return err
+	}
+	for _, batch := range batches {
+		batch.markSaved()
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package orm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+)
+
+// SchemaVersionField 文档结构版本号在文档中的键名，声明了schema_version的消息写入每个文档
+const SchemaVersionField = "_v"
+
+// ErrSchemaVersion 文档版本无法升级到当前版本（比当前版本新或缺少升级函数），可用errors.Is判断
+var ErrSchemaVersion = errors.New("orm: unsupported schema version")
+
+// UpgradeFunc 把文档从注册的版本升级到下一个版本，直接修改doc，嵌套文档解码为bson.M
+type UpgradeFunc func(doc bson.M) error
+
+var upgrades = struct {
+	sync.RWMutex
+	funcs map[string]map[int32]UpgradeFunc
+}{funcs: map[string]map[int32]UpgradeFunc{}}
+
+// RegisterUpgrade 注册消息从from版本升级到from+1的函数，message为proto消息全名
+// 通常在init中调用，重复注册时覆盖；生成的Register<Message>Upgrade调用
+func RegisterUpgrade(message string, from int32, fn UpgradeFunc) {
+	upgrades.Lock()
+	defer upgrades.Unlock()
+	if upgrades.funcs[message] == nil {
+		upgrades.funcs[message] = map[int32]UpgradeFunc{}
+	}
+	upgrades.funcs[message][from] = fn
+}
+
+// UpgradeDocument 按注册的函数把文档从_v记录的版本依次升级到current，没有_v的文档视为版本1
+// 不需要升级时原样返回data；升级后的文档_v为current，第二个返回值表示是否发生了升级
+func UpgradeDocument(message string, current int32, data []byte) ([]byte, bool, error) {
+	version := int64(1)
+	if value, err := bson.Raw(data).LookupErr(SchemaVersionField); err == nil {
+		v, ok := value.AsInt64OK()
+		if !ok {
+			return nil, false, fmt.Errorf("%w: %s has non-numeric %s", ErrSchemaVersion, message, SchemaVersionField)
+		}
+		version = v
+	}
+	if version == int64(current) {
+		return data, false, nil
+	}
+	if version > int64(current) || version < 1 {
+		return nil, false, fmt.Errorf("%w: %s document version %d, current version %d", ErrSchemaVersion, message, version, current)
+	}
+
+	decoder, err := bson.NewDecoder(bsonrw.NewBSONDocumentReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	decoder.DefaultDocumentM()
+	doc := bson.M{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false, err
+	}
+	upgrades.RLock()
+	funcs := upgrades.funcs[message]
+	upgrades.RUnlock()
+	for v := int32(version); v < current; v++ {
+		fn, ok := funcs[v]
+		if !ok {
+			return nil, false, fmt.Errorf("%w: no upgrade registered for %s from version %d", ErrSchemaVersion, message, v)
+		}
+		if err := fn(doc); err != nil {
+			return nil, false, fmt.Errorf("orm: upgrade %s from version %d: %w", message, v, err)
+		}
+	}
+	doc[SchemaVersionField] = current
+	upgraded, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	return upgraded, true, nil
+}
+
+// HasSchemaVersion 文档的_v是否等于current，缺少_v时返回false
+// 投影或聚合的结果不执行升级，生成代码用它判断保存时能否写入_v
+func HasSchemaVersion(data []byte, current int32) bool {
+	value, err := bson.Raw(data).LookupErr(SchemaVersionField)
+	if err != nil {
+		return false
+	}
+	v, ok := value.AsInt64OK()
+	return ok && v == int64(current)
+}
+
+// ChangedFields 返回升级前后值不同的键在keys中的下标，生成代码按字段顺序传入键名，下标即字段索引
+func ChangedFields(before, after bson.Raw, keys ...string) []int {
+	var changed []int
+	for i, key := range keys {
+		old, oldErr := before.LookupErr(key)
+		cur, curErr := after.LookupErr(key)
+		if (oldErr == nil) != (curErr == nil) || !sameValue(old, cur) {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+// sameValue 比较两个值，升级时文档经过bson.M重新编码，嵌套文档按键比较而不依赖键的顺序
+func sameValue(a, b bson.RawValue) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case bson.TypeEmbeddedDocument:
+		aElems, _ := a.Document().Elements()
+		bDoc := b.Document()
+		bElems, _ := bDoc.Elements()
+		if len(aElems) != len(bElems) {
+			return false
+		}
+		for _, elem := range aElems {
+			other, err := bDoc.LookupErr(elem.Key())
+			if err != nil || !sameValue(elem.Value(), other) {
+				return false
+			}
+		}
+		return true
+	case bson.TypeArray:
+		aValues, _ := a.Array().Values()
+		bValues, _ := b.Array().Values()
+		if len(aValues) != len(bValues) {
+			return false
+		}
+		for i := range aValues {
+			if !sameValue(aValues[i], bValues[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return bytes.Equal(a.Value, b.Value)
+}
@@ -0,0 +1,80 @@
+package orm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UUID 声明了id_type = UUID的字段在生成结构体中的类型，存储为binary subtype 4
+type UUID [16]byte
+
+// NewUUID 生成随机的版本4 UUID
+func NewUUID() UUID {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(fmt.Sprintf("orm: generate uuid: %v", err))
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// ParseUUID 解析8-4-4-4-12格式的UUID
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if !IsUUID(s) {
+		return u, fmt.Errorf("orm: invalid uuid %q", s)
+	}
+	hex.Decode(u[:], []byte(s[0:8]+s[9:13]+s[14:18]+s[19:23]+s[24:]))
+	return u, nil
+}
+
+// IsZero 是否为全零的UUID，主键为零值时Insert自动生成
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+// String 返回8-4-4-4-12格式的小写字符串
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// MarshalText JSON等文本格式中使用字符串形式
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText 解析字符串形式的UUID
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// MarshalBSONValue 编码为binary subtype 4
+func (u UUID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(primitive.Binary{Subtype: bson.TypeBinaryUUID, Data: u[:]})
+}
+
+// UnmarshalBSONValue 解码binary subtype 4或旧驱动使用的subtype 3，null解码为零值
+func (u *UUID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t == bson.TypeNull {
+		*u = UUID{}
+		return nil
+	}
+	subtype, bin, ok := bson.RawValue{Type: t, Value: data}.BinaryOK()
+	if !ok || (subtype != bson.TypeBinaryUUID && subtype != bson.TypeBinaryUUIDOld) || len(bin) != len(u) {
+		return fmt.Errorf("orm: cannot decode %v as uuid", t)
+	}
+	copy(u[:], bin)
+	return nil
+}
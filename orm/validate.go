@@ -0,0 +1,61 @@
+package orm
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// ErrValidation 字段值违反了proto中声明的校验规则，可用errors.Is判断
+var ErrValidation = errors.New("orm: validation failed")
+
+// Violation 一条违反的校验规则
+type Violation struct {
+	Path    string // 字段在文档中的路径，数组元素带下标，如tags.0
+	Rule    string // 规则名，与FieldRules中的字段名一致，如min、max_len
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// ValidationError 生成的Validate和TrySet<Field>返回的错误，包含所有违反的规则
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	items := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		items[i] = v.String()
+	}
+	return "orm: validation failed: " + strings.Join(items, "; ")
+}
+
+// Unwrap 使errors.Is(err, ErrValidation)成立
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// NewValidationError 没有违反的规则时返回nil，生成代码使用
+func NewValidationError(violations []Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: violations}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IsEmail 是否为不带显示名的邮箱地址
+func IsEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && addr.Name == ""
+}
+
+// IsUUID 是否为8-4-4-4-12格式的UUID
+func IsUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
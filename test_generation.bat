@@ -1,2 +1,4 @@
 
-tool\protoc\bin\protoc.exe --plugin=protoc-gen-mongo=./bin/protoc-gen-mongo.exe --mongo_out=./example ./example/user.proto
+tool\protoc\bin\protoc.exe --proto_path=. --proto_path=./proto --plugin=protoc-gen-mongo=./bin/protoc-gen-mongo.exe --mongo_out=rollback=true,audit=true,schema_lock=example/user.lock.json:./example ./example/user.proto
+rem 多协程共享实体时使用 --mongo_out=rollback=true,audit=true,thread_safe=true,schema_lock=example/user.lock.json:./example 生成线程安全版本，并用 go run -race example/test_thread_safe.go 验证
+rem 字段编号、类型或键名发生不兼容的变更时生成失败，迁移数据后加上update_schema_lock=true重写example/user.lock.json